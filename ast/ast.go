@@ -3,12 +3,15 @@ package ast
 import (
 	"banana/token"
 	"bytes"
+	"reflect"
 	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // Start of the node in the source
+	End() token.Position // Just past the end of the node
 }
 
 type Statement interface {
@@ -33,6 +36,19 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements) - 1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -42,12 +58,31 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// startOf and endOf fall back to a token of the parent when a child is
+// missing, e.g. after a parse error.
+func startOf(n Node, fallback token.Position) token.Position {
+	if n == nil || reflect.ValueOf(n).IsNil() {
+		return fallback
+	}
+	return n.Pos()
+}
+
+func endOf(n Node, fallback token.Position) token.Position {
+	if n == nil || reflect.ValueOf(n).IsNil() {
+		return fallback
+	}
+	return n.End()
+}
+
 type BlockStatement struct {
 	Token token.Token
 	Statements []Statement
+	EndPos token.Position // Just past the closing }
 }
 func (bs *BlockStatement) statementNode() {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position { return bs.EndPos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -57,12 +92,32 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+type BreakStatement struct {
+	Token token.Token
+}
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position { return bs.Token.End }
+func (bs *BreakStatement) String() string { return bs.TokenLiteral() + ";" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position { return cs.Token.End }
+func (cs *ContinueStatement) String() string { return cs.TokenLiteral() + ";" }
+
 type ExpressionStatement struct {
 	Token token.Token
 	Expression Expression
 }
 func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position { return endOf(es.Expression, es.Token.End) }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -71,13 +126,46 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// ForStatement loops over the elements of Iterable. Key is nil when there
+// is a single loop variable.
+type ForStatement struct {
+	Token token.Token
+	Key *Identifier
+	Value *Identifier
+	Iterable Expression
+	Body *BlockStatement
+}
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position { return endOf(fs.Body, fs.Token.End) }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for(")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// LetStatement is also used for const, which only differs in the token.
 type LetStatement struct {
 	Token token.Token
 	Name *Identifier
 	Val Expression
 }
+func (ls *LetStatement) IsConst() bool { return ls.Token.Type == token.CONST }
 func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position { return endOf(ls.Val, ls.Name.End()) }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
@@ -97,6 +185,8 @@ type ReturnStatement struct {
 }
 func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position { return endOf(rs.ReturnVal, rs.Token.End) }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral())
@@ -108,12 +198,34 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+type WhileStatement struct {
+	Token token.Token
+	Condition Expression
+	Body *BlockStatement
+}
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position { return endOf(ws.Body, ws.Token.End) }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token token.Token
 	Elements []Expression
+	EndPos token.Position // Just past the closing ]
 }
 func (al *ArrayLiteral) expressionNode() {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position { return al.EndPos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	elements := []string{}
@@ -127,21 +239,49 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// AssignExpression stores Val in an existing binding. Op is = or a
+// compound operator such as +=.
+type AssignExpression struct {
+	Token token.Token
+	Target Expression
+	Op string
+	Val Expression
+}
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position { return startOf(ae.Target, ae.Token.Pos) }
+func (ae *AssignExpression) End() token.Position { return endOf(ae.Val, ae.Token.End) }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Op + " ")
+	out.WriteString(ae.Val.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type Boolean struct {
 	Token token.Token
 	Val bool
 }
 func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+func (b *Boolean) End() token.Position { return b.Token.End }
 func (b *Boolean) String() string { return b.Token.Literal }
 
 type CallExpression struct {
 	Token token.Token
 	Fun Expression
 	Args []Expression
+	EndPos token.Position // Just past the closing )
 }
 func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position { return startOf(ce.Fun, ce.Token.Pos) }
+func (ce *CallExpression) End() token.Position { return ce.EndPos }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
@@ -159,9 +299,12 @@ func (ce *CallExpression) String() string {
 type DictLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	EndPos token.Position // Just past the closing }
 }
 func (dl *DictLiteral) expressionNode() {}
 func (dl *DictLiteral) TokenLiteral() string { return dl.Token.Literal }
+func (dl *DictLiteral) Pos() token.Position { return dl.Token.Pos }
+func (dl *DictLiteral) End() token.Position { return dl.EndPos }
 func (dl *DictLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
@@ -175,13 +318,26 @@ func (dl *DictLiteral) String() string {
 	return out.String()
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Val float64
+}
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position { return fl.Token.End }
+func (fl *FloatLiteral) String() string { return fl.Token.Literal }
+
 type FunctionLiteral struct {
 	Token token.Token
 	Parameters []*Identifier
 	Body *BlockStatement
+	Name string // Set when the literal is bound by a let statement
 }
 func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position { return endOf(fl.Body, fl.Token.End) }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
@@ -203,6 +359,8 @@ type Identifier struct {
 }
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position { return i.Token.End }
 func (i *Identifier) String() string { return i.Val }
 
 type IfExpression struct {
@@ -213,6 +371,13 @@ type IfExpression struct {
 }
 func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return endOf(ie.Consequence, ie.Token.End)
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -231,17 +396,20 @@ type IndexExpression struct {
 	Token token.Token
 	Left Expression
 	Index Expression
+	EndPos token.Position // Just past the closing ]
 }
 func (ie *IndexExpression) expressionNode() {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position { return startOf(ie.Left, ie.Token.Pos) }
+func (ie *IndexExpression) End() token.Position { return ie.EndPos }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
-	out.WriteString("]")
-	
+	out.WriteString("])")
+
 	return out.String()
 }
 
@@ -253,6 +421,8 @@ type InfixExpression struct {
 }
 func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position { return startOf(ie.Left, ie.Token.Pos) }
+func (ie *InfixExpression) End() token.Position { return endOf(ie.Right, ie.Token.End) }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -270,6 +440,8 @@ type IntegerLiteral struct {
 }
 func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position { return il.Token.End }
 func (il *IntegerLiteral) String() string { return il.Token.Literal }
 
 type MacroLiteral struct {
@@ -279,6 +451,8 @@ type MacroLiteral struct {
 }
 func (ml *MacroLiteral) expressionNode() {}
 func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position { return ml.Token.Pos }
+func (ml *MacroLiteral) End() token.Position { return endOf(ml.Body, ml.Token.End) }
 func (ml *MacroLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
@@ -294,6 +468,54 @@ func (ml *MacroLiteral) String() string {
 	return out.String()
 }
 
+// MatchExpression evaluates to the body of the first arm whose pattern
+// matches Subject and whose guard, if any, is truthy. It is null when no arm
+// matches.
+type MatchExpression struct {
+	Token token.Token
+	Subject Expression
+	Arms []*MatchArm
+	EndPos token.Position // Just past the closing }
+}
+func (me *MatchExpression) expressionNode() {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position { return me.Token.Pos }
+func (me *MatchExpression) End() token.Position { return me.EndPos }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	out.WriteString("match(")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") {")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// MatchArm is a single pattern => body of a match expression. Guard is nil
+// when the arm has none.
+type MatchArm struct {
+	Pattern Pattern
+	Guard Expression
+	Body Expression
+}
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token token.Token
 	Op string
@@ -301,6 +523,8 @@ type PrefixExpression struct {
 }
 func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position { return endOf(pe.Right, pe.Token.End) }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -317,4 +541,82 @@ type StringLiteral struct {
 }
 func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string { return sl.Token.Literal }
\ No newline at end of file
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position { return sl.Token.End }
+func (sl *StringLiteral) String() string { return sl.Token.Literal }
+
+// Pattern is the left side of a match arm.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// ArrayPattern matches arrays of exactly len(Elements) elements that each
+// match their pattern.
+type ArrayPattern struct {
+	Token token.Token
+	Elements []Pattern
+	EndPos token.Position // Just past the closing ]
+}
+func (ap *ArrayPattern) patternNode() {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position { return ap.Token.Pos }
+func (ap *ArrayPattern) End() token.Position { return ap.EndPos }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// BindingPattern matches any value and binds it to Name.
+type BindingPattern struct {
+	Name *Identifier
+}
+func (bp *BindingPattern) patternNode() {}
+func (bp *BindingPattern) TokenLiteral() string { return bp.Name.TokenLiteral() }
+func (bp *BindingPattern) Pos() token.Position { return bp.Name.Pos() }
+func (bp *BindingPattern) End() token.Position { return bp.Name.End() }
+func (bp *BindingPattern) String() string { return bp.Name.String() }
+
+// DictPattern matches dicts that have all of Keys, the value of each key
+// must match the pattern at the same index of Vals. Other keys are ignored.
+type DictPattern struct {
+	Token token.Token
+	Keys []*LiteralPattern
+	Vals []Pattern
+	EndPos token.Position // Just past the closing }
+}
+func (dp *DictPattern) patternNode() {}
+func (dp *DictPattern) TokenLiteral() string { return dp.Token.Literal }
+func (dp *DictPattern) Pos() token.Position { return dp.Token.Pos }
+func (dp *DictPattern) End() token.Position { return dp.EndPos }
+func (dp *DictPattern) String() string {
+	pairs := []string{}
+	for i, key := range dp.Keys {
+		pairs = append(pairs, key.String() + ": " + dp.Vals[i].String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// LiteralPattern matches values equal to Val, which is a literal or a
+// negated number.
+type LiteralPattern struct {
+	Val Expression
+}
+func (lp *LiteralPattern) patternNode() {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Val.TokenLiteral() }
+func (lp *LiteralPattern) Pos() token.Position { return lp.Val.Pos() }
+func (lp *LiteralPattern) End() token.Position { return lp.Val.End() }
+func (lp *LiteralPattern) String() string { return lp.Val.String() }
+
+// WildcardPattern is _, it matches any value without binding it.
+type WildcardPattern struct {
+	Token token.Token
+}
+func (wp *WildcardPattern) patternNode() {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) Pos() token.Position { return wp.Token.Pos }
+func (wp *WildcardPattern) End() token.Position { return wp.Token.End }
+func (wp *WildcardPattern) String() string { return wp.Token.Literal }
@@ -31,4 +31,20 @@ func TestString(t *testing.T) {
 	if program.String() != "let myVar = anotherVar;" {
 		t.Errorf("program.String() wrong, got=%q", program.String())
 	}
-}
\ No newline at end of file
+}
+func TestIndexExpressionString(t *testing.T) {
+	expr := &IndexExpression{
+		Token: token.Token{Type: token.LBRACKET, Literal: "["},
+		Left: &Identifier{
+			Token: token.Token{Type: token.ID, Literal: "a"},
+			Val: "a",
+		},
+		Index: &IntegerLiteral{
+			Token: token.Token{Type: token.INT, Literal: "0"},
+			Val: 0,
+		},
+	}
+	if expr.String() != "(a[0])" {
+		t.Errorf("expr.String() wrong, got=%q", expr.String())
+	}
+}
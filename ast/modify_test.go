@@ -112,6 +112,58 @@ func TestModify(t * testing.T) {
 		{
             &ArrayLiteral{Elements: []Expression{one(), one()}},
             &ArrayLiteral{Elements: []Expression{two(), two()}},
+        },
+		{
+            &WhileStatement{
+                Condition: one(),
+                Body: &BlockStatement{
+                    Statements: []Statement{
+                        &ExpressionStatement{Expression: one()},
+                    },
+                },
+            },
+            &WhileStatement{
+                Condition: two(),
+                Body: &BlockStatement{
+                    Statements: []Statement{
+                        &ExpressionStatement{Expression: two()},
+                    },
+                },
+            },
+        },
+		{
+            &ForStatement{
+                Iterable: one(),
+                Body: &BlockStatement{
+                    Statements: []Statement{
+                        &ExpressionStatement{Expression: one()},
+                    },
+                },
+            },
+            &ForStatement{
+                Iterable: two(),
+                Body: &BlockStatement{
+                    Statements: []Statement{
+                        &ExpressionStatement{Expression: two()},
+                    },
+                },
+            },
+        },
+		{
+            &MatchExpression{
+                Subject: one(),
+                Arms: []*MatchArm{
+                    {Pattern: &WildcardPattern{}, Guard: one(), Body: one()},
+                    {Pattern: &WildcardPattern{}, Body: one()},
+                },
+            },
+            &MatchExpression{
+                Subject: two(),
+                Arms: []*MatchArm{
+                    {Pattern: &WildcardPattern{}, Guard: two(), Body: two()},
+                    {Pattern: &WildcardPattern{}, Body: two()},
+                },
+            },
         },
     }
 
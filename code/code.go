@@ -1,16 +1,100 @@
 package code
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"sort"
+	"banana/token"
 )
 
 type Instructions []byte
 
+// String disassembles the instructions, one per line, prefixed with their
+// offset.
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+	i := 0
+	for i < len(ins) {
+		def, err := LookUp(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		operands, read := ReadOperands(def, ins[i + 1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
 type OpCode byte
 
 const (
 	OpConstant OpCode = iota
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpTrue
+	OpFalse
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpMinus
+	OpBang
+	OpPop
+	OpJumpNotTruthy
+	OpJump
+	OpNull
+	OpGetGlobal
+	OpSetGlobal
+	OpArray
+	OpDict
+	OpIndex
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpClosure
+	OpGetFree
+	OpCurrentClosure
+	OpMod
+	OpPow
+	OpGreaterEqual
+	OpIter
+	OpIterNext
+	OpSetFree
+	OpGetLocalCell
+	OpGetFreeCell
+	OpSetIndex
+	OpDup2
+	OpDup
+	OpMatchArray
+	OpMatchDict
+	OpMatchKey
+	OpLessThan
+	OpLessEqual
+	OpBindLocal
 )
 
 type Definition struct {
@@ -20,6 +104,60 @@ type Definition struct {
 
 var definitions = map[OpCode]*Definition{
 	OpConstant: {"OpConstant", []int{2}},
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpTrue: {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpEqual: {"OpEqual", []int{}},
+	OpNotEqual: {"OpNotEqual", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpMinus: {"OpMinus", []int{}},
+	OpBang: {"OpBang", []int{}},
+	OpPop: {"OpPop", []int{}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump: {"OpJump", []int{2}},
+	OpNull: {"OpNull", []int{}},
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpArray: {"OpArray", []int{2}},
+	OpDict: {"OpDict", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+	OpCall: {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn: {"OpReturn", []int{}},
+	OpGetLocal: {"OpGetLocal", []int{1}},
+	OpSetLocal: {"OpSetLocal", []int{1}},
+	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+	OpClosure: {"OpClosure", []int{2, 1}},
+	OpGetFree: {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpMod: {"OpMod", []int{}},
+	OpPow: {"OpPow", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpIter: {"OpIter", []int{}},
+	// Jump target once the iterator is exhausted, number of loop variables
+	OpIterNext: {"OpIterNext", []int{2, 1}},
+	OpSetFree: {"OpSetFree", []int{1}},
+	// Push the cell of a variable instead of its value, for OpClosure
+	OpGetLocalCell: {"OpGetLocalCell", []int{1}},
+	OpGetFreeCell: {"OpGetFreeCell", []int{1}},
+	OpSetIndex: {"OpSetIndex", []int{}},
+	// Duplicate the top two elements of the stack
+	OpDup2: {"OpDup2", []int{}},
+	OpDup: {"OpDup", []int{}},
+	// Whether the popped value is an array of the given length
+	OpMatchArray: {"OpMatchArray", []int{2}},
+	// Whether the popped value is a dict
+	OpMatchDict: {"OpMatchDict", []int{}},
+	// Whether the value below the popped key is a dict holding that key
+	OpMatchKey: {"OpMatchKey", []int{}},
+	OpLessThan: {"OpLessThan", []int{}},
+	OpLessEqual: {"OpLessEqual", []int{}},
+	// Like OpSetLocal but replaces a cell in the slot instead of writing
+	// through it, closures made before keep the value they captured
+	OpBindLocal: {"OpBindLocal", []int{1}},
 }
 
 func LookUp(op byte) (*Definition, error) {
@@ -47,8 +185,55 @@ func Make(op OpCode, operands ...int) []byte {
 		switch width {
 		case 2:
 			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
 		}
 		offset += width
 	}
 	return instruction
-}
\ No newline at end of file
+}
+
+// ReadOperands decodes the operands of an instruction, it is the inverse of
+// Make. It returns the operands and the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// Positions maps instructions back to the source they were compiled from.
+// Entries are sorted by offset and each one covers the instructions up to
+// the next entry.
+type Positions []Position
+
+type Position struct {
+	Offset int
+	Pos token.Position
+}
+
+// Lookup returns the source position of the instruction at offset, or the
+// zero position if it is not known.
+func (p Positions) Lookup(offset int) token.Position {
+	i := sort.Search(len(p), func(i int) bool { return p[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return p[i - 1].Pos
+}
@@ -1,6 +1,9 @@
 package code
 
-import "testing"
+import (
+	"testing"
+	"banana/token"
+)
 
 func TestMake(t *testing.T) {
 	tests := []struct {
@@ -9,6 +12,9 @@ func TestMake(t *testing.T) {
 		expected []byte
 	} {
 		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
 	}
 	for _, tt := range tests {
 		instruction := Make(tt.op, tt.operands...)
@@ -22,3 +28,77 @@ func TestMake(t *testing.T) {
 		}
 	}
 }
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+	if concatted.String() != expected {
+		t.Errorf("Instructions wrongly formatted, expected=%q, got=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op OpCode
+		operands []int
+		bytesRead int
+	} {
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+		def, err := LookUp(byte(tt.op))
+		if err != nil {
+			t.Fatalf("Definition not found: %q", err)
+		}
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong, expected=%d, got=%d", tt.bytesRead, n)
+		}
+		for i, expected := range tt.operands {
+			if operandsRead[i] != expected {
+				t.Errorf("Operand wrong, expected=%d, got=%d", expected, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestPositionsLookup(t *testing.T) {
+	positions := Positions{
+		{Offset: 2, Pos: token.Position{Line: 1, Column: 1}},
+		{Offset: 5, Pos: token.Position{Line: 2, Column: 3}},
+	}
+	tests := []struct {
+		offset int
+		expected string
+	} {
+		{0, "-"},
+		{2, "1:1"},
+		{4, "1:1"},
+		{5, "2:3"},
+		{100, "2:3"},
+	}
+	for _, tt := range tests {
+		got := positions.Lookup(tt.offset)
+		if got.String() != tt.expected {
+			t.Errorf("Wrong position at %d, expected=%s, got=%s", tt.offset, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"banana/code"
+	"banana/object"
+)
+
+// A .bnc file is laid out as
+//
+//	magic    [4]byte  "BNC\x00"
+//	version  uint16
+//	constants
+//	    count uint32, then per constant a tag byte followed by its data
+//	instructions
+//	    length uint32, then the raw instructions
+//	positions
+//	    count uint32, then per entry the instruction offset and the line,
+//	    column and offset in the source, each a uint32
+//	checksum uint32   CRC-32 (IEEE) of everything before it
+//
+// A function constant holds its instructions and positions laid out the
+// same way. All integers are big endian.
+
+// FileVersion must be bumped whenever the layout above, the constant tags
+// or the opcodes change, files from an older compiler can't run otherwise.
+const FileVersion = 3
+
+var fileMagic = []byte{'B', 'N', 'C', 0}
+
+const (
+	constInteger byte = iota + 1
+	constString
+	constCompiledFunction
+	constFloat
+)
+
+func (b *ByteCode) Encode(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.Write(fileMagic)
+	binary.Write(&buf, binary.BigEndian, uint16(FileVersion))
+	binary.Write(&buf, binary.BigEndian, uint32(len(b.Constants)))
+	for _, constant := range b.Constants {
+		err := encodeConstant(&buf, constant)
+		if err != nil {
+			return err
+		}
+	}
+	writeBytes(&buf, b.Instructions)
+	writePositions(&buf, b.Positions)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func encodeConstant(buf *bytes.Buffer, constant object.Object) error {
+	switch constant := constant.(type) {
+	case *object.Integer:
+		buf.WriteByte(constInteger)
+		binary.Write(buf, binary.BigEndian, constant.Val)
+	case *object.String:
+		buf.WriteByte(constString)
+		writeBytes(buf, []byte(constant.Val))
+	case *object.Float:
+		buf.WriteByte(constFloat)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(constant.Val))
+	case *object.CompiledFunction:
+		buf.WriteByte(constCompiledFunction)
+		binary.Write(buf, binary.BigEndian, uint32(constant.NumLocals))
+		binary.Write(buf, binary.BigEndian, uint32(constant.NumParameters))
+		writeBytes(buf, constant.Instructions)
+		writePositions(buf, constant.Positions)
+	default:
+		return fmt.Errorf("Cannot encode constant of type %s", constant.Type())
+	}
+	return nil
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(b)))
+	buf.Write(b)
+}
+
+func writePositions(buf *bytes.Buffer, positions code.Positions) {
+	binary.Write(buf, binary.BigEndian, uint32(len(positions)))
+	for _, p := range positions {
+		for _, n := range []int{p.Offset, p.Pos.Line, p.Pos.Column, p.Pos.Offset} {
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+	}
+}
+
+func Decode(r io.Reader) (*ByteCode, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := len(fileMagic) + 2
+	if len(data) < headerLen + 4 || !bytes.Equal(data[:len(fileMagic)], fileMagic) {
+		return nil, fmt.Errorf("Not a banana bytecode file")
+	}
+	version := binary.BigEndian.Uint16(data[len(fileMagic):])
+	if version != FileVersion {
+		return nil, fmt.Errorf("Unsupported bytecode version %d, expected %d", version, FileVersion)
+	}
+	body := data[:len(data) - 4]
+	checksum := binary.BigEndian.Uint32(data[len(data) - 4:])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return nil, fmt.Errorf("Bytecode checksum mismatch, the file is corrupted")
+	}
+
+	d := &decoder{data: body[headerLen:]}
+	numConstants := d.readUint32()
+	constants := []object.Object{}
+	for i := uint32(0); i < numConstants && d.err == nil; i++ {
+		constants = append(constants, d.readConstant())
+	}
+	instructions := d.readBytes()
+	positions := d.readPositions()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.data) != 0 {
+		return nil, fmt.Errorf("Malformed bytecode file: %d trailing bytes", len(d.data))
+	}
+	byteCode := &ByteCode{
+		Instructions: code.Instructions(instructions),
+		Positions: positions,
+		Constants: constants,
+	}
+	err = byteCode.verify()
+	if err != nil {
+		return nil, err
+	}
+	return byteCode, nil
+}
+
+// decoder reads from data and records the first error, later reads become
+// no-ops so callers only need to check err once.
+type decoder struct {
+	data []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.err = fmt.Errorf("Malformed bytecode file: unexpected end of data")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) readUint32() uint32 {
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) readBytes() []byte {
+	n := d.readUint32()
+	b := d.take(int(n))
+	if b == nil {
+		return nil
+	}
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out
+}
+
+// readPositions reads a position table, the offsets only have to be sorted
+// for errors to point at the right place so they aren't verified.
+func (d *decoder) readPositions() code.Positions {
+	n := d.readUint32()
+	// Every entry takes 16 bytes, a bogus count fails before allocating.
+	if d.err == nil && uint64(n) * 16 > uint64(len(d.data)) {
+		d.err = fmt.Errorf("Malformed bytecode file: unexpected end of data")
+	}
+	if d.err != nil {
+		return nil
+	}
+	positions := make(code.Positions, n)
+	for i := range positions {
+		positions[i].Offset = int(d.readUint32())
+		positions[i].Pos.Line = int(d.readUint32())
+		positions[i].Pos.Column = int(d.readUint32())
+		positions[i].Pos.Offset = int(d.readUint32())
+	}
+	return positions
+}
+
+func (d *decoder) readConstant() object.Object {
+	tag := d.take(1)
+	if tag == nil {
+		return nil
+	}
+	switch tag[0] {
+	case constInteger:
+		b := d.take(8)
+		if b == nil {
+			return nil
+		}
+		return &object.Integer{Val: int64(binary.BigEndian.Uint64(b))}
+	case constFloat:
+		b := d.take(8)
+		if b == nil {
+			return nil
+		}
+		return &object.Float{Val: math.Float64frombits(binary.BigEndian.Uint64(b))}
+	case constString:
+		return &object.String{Val: string(d.readBytes())}
+	case constCompiledFunction:
+		numLocals := d.readUint32()
+		numParameters := d.readUint32()
+		instructions := d.readBytes()
+		positions := d.readPositions()
+		return &object.CompiledFunction{
+			Instructions: instructions,
+			Positions: positions,
+			NumLocals: int(numLocals),
+			NumParameters: int(numParameters),
+		}
+	default:
+		d.err = fmt.Errorf("Malformed bytecode file: unknown constant tag %d", tag[0])
+		return nil
+	}
+}
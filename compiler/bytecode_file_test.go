@@ -0,0 +1,137 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"banana/code"
+	"banana/object"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	input := `
+	let greeting = "hello";
+	let ratio = 0.75;
+	let add = fn(a, b) { let c = a + b; c };
+	add(1, -2);
+	`
+	compiler := New()
+	err := compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+	original := compiler.ByteCode()
+	var buf bytes.Buffer
+	err = original.Encode(&buf)
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+	if decoded.Disassemble() != original.Disassemble() {
+		t.Errorf("Decoded bytecode differs, expected=\n%s\ngot=\n%s", original.Disassemble(), decoded.Disassemble())
+	}
+	fn, ok := decoded.Constants[2].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("Constant 2 is not CompiledFunction, got=%T", decoded.Constants[2])
+	}
+	if fn.NumLocals != 3 || fn.NumParameters != 2 {
+		t.Errorf("Wrong function metadata, locals=%d, params=%d", fn.NumLocals, fn.NumParameters)
+	}
+	originalFn := original.Constants[2].(*object.CompiledFunction)
+	if fmt.Sprint(decoded.Positions) != fmt.Sprint(original.Positions) ||
+		fmt.Sprint(fn.Positions) != fmt.Sprint(originalFn.Positions) {
+		t.Errorf("Decoded positions differ, expected=%v %v, got=%v %v",
+			original.Positions, originalFn.Positions, decoded.Positions, fn.Positions)
+	}
+	if len(fn.Positions) == 0 {
+		t.Errorf("Function has no positions")
+	}
+}
+
+// encodeForTest encodes instructions and constants as they are, the file has
+// a valid checksum whatever they contain.
+func encodeForTest(t *testing.T, ins code.Instructions, constants []object.Object) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	err := (&ByteCode{Instructions: ins, Constants: constants}).Encode(&buf)
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeErrors(t *testing.T) {
+	byteCode := &ByteCode{
+		Instructions: code.Make(code.OpConstant, 0),
+		Constants: []object.Object{&object.Integer{Val: 7}},
+	}
+	var buf bytes.Buffer
+	err := byteCode.Encode(&buf)
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+	valid := buf.Bytes()
+
+	corrupted := append([]byte{}, valid...)
+	corrupted[10] ^= 0xff
+
+	wrongVersion := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(wrongVersion[4:], FileVersion + 1)
+
+	seven := []object.Object{&object.Integer{Val: 7}}
+	leftOnStack := concatInstructions([]code.Instructions{
+		code.Make(code.OpTrue),
+		code.Make(code.OpJumpNotTruthy, 6),
+		code.Make(code.OpNull),
+		code.Make(code.OpNull),
+		code.Make(code.OpPop),
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+		expectedMessage string
+	} {
+		{"empty", []byte{}, "Not a banana bytecode file"},
+		{"bad magic", append([]byte("XXXX"), valid[4:]...), "Not a banana bytecode file"},
+		{"corrupted", corrupted, "Bytecode checksum mismatch, the file is corrupted"},
+		{"wrong version", wrongVersion, fmt.Sprintf("Unsupported bytecode version %d, expected %d", FileVersion + 1, FileVersion)},
+		{"constant out of range", encodeForTest(t, code.Make(code.OpConstant, 5), nil),
+			"Malformed bytecode file: constant 5 out of range at 0000 in the main program"},
+		{"truncated operand", encodeForTest(t, code.Make(code.OpConstant, 0)[:2], seven),
+			"Malformed bytecode file: truncated OpConstant at 0000 in the main program"},
+		{"stack underflow", encodeForTest(t, code.Make(code.OpPop), nil),
+			"Malformed bytecode file: stack underflow at 0000 in the main program"},
+		{"unknown opcode", encodeForTest(t, code.Instructions{255}, nil),
+			"Malformed bytecode file: unknown opcode 255 at 0000 in the main program"},
+		{"jump into an instruction", encodeForTest(t, concatInstructions([]code.Instructions{code.Make(code.OpJump, 4), code.Make(code.OpConstant, 0)}), seven),
+			"Malformed bytecode file: OpJump to 4 at 0000 is not an instruction in the main program"},
+		{"jump past the end", encodeForTest(t, code.Make(code.OpJump, 9), nil),
+			"Malformed bytecode file: OpJump to 9 at 0000 is not an instruction in the main program"},
+		{"branches leave different depths", encodeForTest(t, leftOnStack, nil),
+			"Malformed bytecode file: stack depth 0 and 2 at 0006 in the main program"},
+		{"closure over a non-function", encodeForTest(t, code.Make(code.OpClosure, 0, 0), seven),
+			"Malformed bytecode file: closure over constant 0 at 0000 is not a function in the main program"},
+		{"local out of range", encodeForTest(t, code.Make(code.OpClosure, 0, 0), []object.Object{
+			&object.CompiledFunction{Instructions: concatInstructions([]code.Instructions{code.Make(code.OpGetLocal, 1), code.Make(code.OpReturnValue)}), NumLocals: 1},
+		}), "Malformed bytecode file: local 1 out of range at 0000 in constant 0"},
+		{"missing free variable", encodeForTest(t, code.Make(code.OpClosure, 0, 0), []object.Object{
+			&object.CompiledFunction{Instructions: concatInstructions([]code.Instructions{code.Make(code.OpGetFree, 0), code.Make(code.OpReturnValue)})},
+		}), "Malformed bytecode file: closure at 0000 has 0 free variables, needs 1 in the main program"},
+	}
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.data))
+		if err == nil {
+			t.Errorf("%s: expected error but got none", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expectedMessage) {
+			t.Errorf("%s: wrong error, expected=%q, got=%q", tt.name, tt.expectedMessage, err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package compiler
+
+import (
+	"fmt"
+	"banana/code"
+	"banana/evaluator"
+	"banana/object"
+)
+
+// instruction is one decoded instruction of a stream being verified.
+type instruction struct {
+	pos int
+	next int // Position of the instruction after it
+	op code.OpCode
+	operands []int
+}
+
+// verify checks the instructions of the main program and of every function
+// constant, a file that passed the checksum must still not make the VM read
+// outside its stack, its constants or its instructions.
+func (b *ByteCode) verify() error {
+	decoded := make([][]instruction, len(b.Constants))
+	freeUsed := make([]int, len(b.Constants))
+	for i, constant := range b.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		if fn.NumParameters > fn.NumLocals {
+			return fmt.Errorf("Malformed bytecode file: constant %d has more parameters than locals", i)
+		}
+		instructions, err := decodeInstructions(fn.Instructions)
+		if err != nil {
+			return fmt.Errorf("Malformed bytecode file: %s in constant %d", err, i)
+		}
+		decoded[i] = instructions
+		freeUsed[i] = numFreeUsed(instructions)
+	}
+
+	main, err := decodeInstructions(b.Instructions)
+	if err == nil {
+		err = b.verifyInstructions(main, len(b.Instructions), 0, freeUsed)
+	}
+	if err == nil && numFreeUsed(main) > 0 {
+		err = fmt.Errorf("free variable outside of a closure")
+	}
+	if err != nil {
+		return fmt.Errorf("Malformed bytecode file: %s in the main program", err)
+	}
+	for i, instructions := range decoded {
+		fn, ok := b.Constants[i].(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		err := b.verifyInstructions(instructions, len(fn.Instructions), fn.NumLocals, freeUsed)
+		if err != nil {
+			return fmt.Errorf("Malformed bytecode file: %s in constant %d", err, i)
+		}
+	}
+	return nil
+}
+
+// decodeInstructions splits ins into instructions, every opcode must be
+// known and have all of its operands.
+func decodeInstructions(ins code.Instructions) ([]instruction, error) {
+	instructions := []instruction{}
+	for i := 0; i < len(ins); {
+		def, err := code.LookUp(ins[i])
+		if err != nil {
+			return nil, fmt.Errorf("unknown opcode %d at %04d", ins[i], i)
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if i + 1 + width > len(ins) {
+			return nil, fmt.Errorf("truncated %s at %04d", def.Name, i)
+		}
+		operands, read := code.ReadOperands(def, ins[i + 1:])
+		instructions = append(instructions, instruction{
+			pos: i,
+			next: i + 1 + read,
+			op: code.OpCode(ins[i]),
+			operands: operands,
+		})
+		i += 1 + read
+	}
+	return instructions, nil
+}
+
+// numFreeUsed is the number of free variables a closure over the function
+// needs, one past the highest free index it refers to.
+func numFreeUsed(instructions []instruction) int {
+	n := 0
+	for _, ins := range instructions {
+		switch ins.op {
+		case code.OpGetFree, code.OpSetFree, code.OpGetFreeCell:
+			if ins.operands[0] + 1 > n {
+				n = ins.operands[0] + 1
+			}
+		}
+	}
+	return n
+}
+
+// verifyInstructions checks the operands of every instruction and follows
+// each path through them to make sure none pops more than was pushed.
+func (b *ByteCode) verifyInstructions(instructions []instruction, length int, numLocals int, freeUsed []int) error {
+	index := map[int]int{}
+	for i, ins := range instructions {
+		index[ins.pos] = i
+	}
+	isTarget := func(pos int) bool {
+		_, ok := index[pos]
+		return ok || pos == length
+	}
+	for _, ins := range instructions {
+		def, _ := code.LookUp(byte(ins.op))
+		switch ins.op {
+		case code.OpConstant:
+			if ins.operands[0] >= len(b.Constants) {
+				return fmt.Errorf("constant %d out of range at %04d", ins.operands[0], ins.pos)
+			}
+		case code.OpClosure:
+			if ins.operands[0] >= len(b.Constants) {
+				return fmt.Errorf("constant %d out of range at %04d", ins.operands[0], ins.pos)
+			}
+			if _, ok := b.Constants[ins.operands[0]].(*object.CompiledFunction); !ok {
+				return fmt.Errorf("closure over constant %d at %04d is not a function", ins.operands[0], ins.pos)
+			}
+			if ins.operands[1] < freeUsed[ins.operands[0]] {
+				return fmt.Errorf("closure at %04d has %d free variables, needs %d", ins.pos, ins.operands[1], freeUsed[ins.operands[0]])
+			}
+		case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext:
+			if !isTarget(ins.operands[0]) {
+				return fmt.Errorf("%s to %d at %04d is not an instruction", def.Name, ins.operands[0], ins.pos)
+			}
+		case code.OpGetLocal, code.OpSetLocal, code.OpGetLocalCell, code.OpBindLocal:
+			if ins.operands[0] >= numLocals {
+				return fmt.Errorf("local %d out of range at %04d", ins.operands[0], ins.pos)
+			}
+		case code.OpGetBuiltin:
+			if ins.operands[0] >= len(evaluator.Builtins) {
+				return fmt.Errorf("builtin %d out of range at %04d", ins.operands[0], ins.pos)
+			}
+		case code.OpDict:
+			if ins.operands[0] % 2 != 0 {
+				return fmt.Errorf("dict of %d elements at %04d", ins.operands[0], ins.pos)
+			}
+		}
+	}
+	return verifyStackDepth(instructions, index, length)
+}
+
+// verifyStackDepth walks every path from the first instruction with the
+// depth of the stack at each one. An instruction reached twice must be
+// reached with the same depth, so loops can't grow or drain the stack.
+func verifyStackDepth(instructions []instruction, index map[int]int, length int) error {
+	if length == 0 {
+		return nil
+	}
+	depths := map[int]int{}
+	work := []int{0}
+	depths[0] = 0
+	// reach records that pos is reached with depth and queues it the first
+	// time, the end of the stream just stops the path.
+	reach := func(pos, depth int) error {
+		if pos == length {
+			return nil
+		}
+		if seen, ok := depths[pos]; ok {
+			if seen != depth {
+				return fmt.Errorf("stack depth %d and %d at %04d", seen, depth, pos)
+			}
+			return nil
+		}
+		depths[pos] = depth
+		work = append(work, pos)
+		return nil
+	}
+	for len(work) > 0 {
+		pos := work[len(work) - 1]
+		work = work[:len(work) - 1]
+		ins := instructions[index[pos]]
+		depth := depths[pos]
+		pops, pushes, ok := stackEffect(ins)
+		if !ok {
+			def, _ := code.LookUp(byte(ins.op))
+			return fmt.Errorf("unverifiable %s at %04d", def.Name, ins.pos)
+		}
+		if depth < pops {
+			return fmt.Errorf("stack underflow at %04d", ins.pos)
+		}
+		depth = depth - pops + pushes
+		next := ins.next
+		var err error
+		switch ins.op {
+		case code.OpReturnValue, code.OpReturn:
+			continue
+		case code.OpJump:
+			err = reach(ins.operands[0], depth)
+		case code.OpJumpNotTruthy:
+			err = reach(ins.operands[0], depth)
+			if err == nil {
+				err = reach(next, depth)
+			}
+		case code.OpIterNext:
+			// An exhausted iterator jumps with nothing pushed.
+			err = reach(ins.operands[0], depth - ins.operands[1])
+			if err == nil {
+				err = reach(next, depth)
+			}
+		default:
+			err = reach(next, depth)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stackEffect returns how many values ins pops and pushes when it falls
+// through to the next instruction, ok is false for an opcode it doesn't
+// know so a new one can't slip past the verifier.
+func stackEffect(ins instruction) (pops, pushes int, ok bool) {
+	switch ins.op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull, code.OpGetGlobal,
+		code.OpGetLocal, code.OpGetBuiltin, code.OpGetFree, code.OpCurrentClosure,
+		code.OpGetLocalCell, code.OpGetFreeCell:
+		return 0, 1, true
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual,
+		code.OpLessThan, code.OpLessEqual, code.OpIndex, code.OpMatchKey:
+		return 2, 1, true
+	case code.OpMinus, code.OpBang, code.OpIter, code.OpMatchArray, code.OpMatchDict:
+		return 1, 1, true
+	case code.OpPop, code.OpJumpNotTruthy, code.OpSetGlobal, code.OpSetLocal,
+		code.OpSetFree, code.OpBindLocal, code.OpReturnValue:
+		return 1, 0, true
+	case code.OpJump, code.OpReturn:
+		return 0, 0, true
+	case code.OpArray, code.OpDict:
+		return ins.operands[0], 1, true
+	case code.OpCall:
+		return ins.operands[0] + 1, 1, true
+	case code.OpClosure:
+		return ins.operands[1], 1, true
+	case code.OpIterNext:
+		// The iterator stays below the loop variables.
+		return 1, 1 + ins.operands[1], true
+	case code.OpSetIndex:
+		return 3, 1, true
+	case code.OpDup:
+		return 1, 2, true
+	case code.OpDup2:
+		return 2, 4, true
+	}
+	return 0, 0, false
+}
@@ -1,35 +1,957 @@
 package compiler
 
 import (
+	"fmt"
+	"sort"
 	"banana/ast"
 	"banana/code"
+	"banana/evaluator"
 	"banana/object"
+	"banana/token"
 )
 
 type Compiler struct {
-	instructions code.Instructions
 	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes []CompilationScope
+	scopeIndex int
+
+	pos token.Position // Position of the innermost node being compiled
+
+	optimize bool
+}
+
+type EmittedInstruction struct {
+	OpCode code.OpCode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function currently being
+// compiled, the outermost scope is the main program.
+type CompilationScope struct {
+	instructions code.Instructions
+	positions code.Positions
+	lastInstruction EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops []*loop // Enclosing loops, innermost last
+}
+
+// loop records where a loop starts, the target of continue, and the
+// placeholder jumps of its breaks that are patched once its end is known.
+type loop struct {
+	start int
+	breaks []int
 }
 
 func New() *Compiler {
-	return &Compiler{
+	mainScope := CompilationScope{
 		instructions: code.Instructions{},
+		lastInstruction: EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	symbolTable := NewSymbolTable()
+	for i, v := range evaluator.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	return &Compiler{
 		constants: []object.Object{},
+		symbolTable: symbolTable,
+		scopes: []CompilationScope{mainScope},
+		scopeIndex: 0,
+		optimize: true,
 	}
 }
 
+// NewWithState creates a compiler that keeps defining globals and constants
+// on top of the ones from a previous compilation, e.g. earlier REPL lines.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// Compile compiles node, the instructions it emits are attributed to the
+// innermost node with a position so runtime errors can point at it.
 func (c *Compiler) Compile(node ast.Node) error {
+	if pos := node.Pos(); pos.IsValid() {
+		saved := c.pos
+		c.pos = pos
+		defer func() { c.pos = saved }()
+	}
+	return c.compileNode(node)
+}
+
+func (c *Compiler) compileNode(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			err := c.Compile(s)
+			if err != nil {
+				return err
+			}
+		}
+	// Statements
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			err := c.Compile(s)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("Break outside of a loop")
+		}
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("Continue outside of a loop")
+		}
+		c.emit(code.OpJump, l.start)
+	case *ast.ExpressionStatement:
+		err := c.Compile(node.Expression)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.LetStatement:
+		if fn, ok := node.Val.(*ast.FunctionLiteral); ok {
+			return c.compileLetFunction(node, fn)
+		}
+		err := c.Compile(node.Val)
+		if err != nil {
+			return err
+		}
+		if node.IsConst() {
+			return c.defineConst(node.Name.Val, node.Pos())
+		}
+		return c.defineSymbol(node.Name.Val)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.ReturnStatement:
+		err := c.Compile(node.ReturnVal)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	// Expressions
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			err := c.Compile(e)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.Boolean:
+		if node.Val {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.CallExpression:
+		err := c.Compile(node.Fun)
+		if err != nil {
+			return err
+		}
+		for _, a := range node.Args {
+			err := c.Compile(a)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Args))
+	case *ast.DictLiteral:
+		return c.compileDictLiteral(node)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, nil)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Val)
+		if !ok {
+			return fmt.Errorf("Identifier not found: %s", node.Val)
+		}
+		c.loadSymbol(symbol)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.MatchExpression:
+		return c.compileMatchExpression(node)
+	case *ast.IndexExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Index)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.InfixExpression:
+		if folded, ok := c.foldConstant(node); ok {
+			c.emitConstant(folded)
+			return nil
+		}
+		return c.compileInfixExpression(node)
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Val: node.Val}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Val: node.Val}
+		c.emit(code.OpConstant, c.addConstant(float))
+	case *ast.PrefixExpression:
+		if folded, ok := c.foldConstant(node); ok {
+			c.emitConstant(folded)
+			return nil
+		}
+		err := c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+		switch node.Op {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("Unknown operator %s", node.Op)
+		}
+	case *ast.StringLiteral:
+		str := &object.String{Val: node.Val}
+		c.emit(code.OpConstant, c.addConstant(str))
+	}
+	return nil
+}
+
+// compileLetFunction binds a function literal before compiling it, so the
+// function refers to itself through the binding and sees later assignments
+// like it does in the evaluator. A const can't change, a function bound by
+// one loads itself directly.
+func (c *Compiler) compileLetFunction(node *ast.LetStatement, fn *ast.FunctionLiteral) error {
+	name := node.Name.Val
+	err := c.checkRedeclaration(name)
+	if err != nil {
+		return err
+	}
+	if node.IsConst() {
+		self := Symbol{Name: name, Const: true, Declared: node.Pos()}
+		err = c.compileFunctionLiteral(fn, &self)
+		if err != nil {
+			return err
+		}
+		c.storeSymbol(c.symbolTable.DefineConst(name, node.Pos()))
+		return nil
+	}
+	symbol := c.symbolTable.Define(name)
+	err = c.compileFunctionLiteral(fn, nil)
+	if err != nil {
+		return err
+	}
+	c.storeSymbol(symbol)
+	return nil
+}
+
+// compileFunctionLiteral compiles node into a closure, self is the const the
+// function is bound to, if any.
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, self *Symbol) error {
+	c.enterScope()
+	if self != nil {
+		c.symbolTable.DefineFunctionName(self.Name, self.Declared)
+	}
+	for _, p := range node.Parameters {
+		c.symbolTable.defineSlot(p.Val)
+	}
+	err := c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+	// The cells of the free variables are pushed in the enclosing scope so
+	// OpClosure can copy them off the stack.
+	for _, s := range freeSymbols {
+		c.loadCell(s)
+	}
+	compiledFn := &object.CompiledFunction{
+		Instructions: instructions,
+		Positions: positions,
+		NumLocals: numLocals,
+		NumParameters: len(node.Parameters),
+	}
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+	return nil
+}
+
+// defineSymbol defines name in the current scope and stores the value on
+// top of the stack in it.
+func (c *Compiler) defineSymbol(name string) error {
+	err := c.checkRedeclaration(name)
+	if err != nil {
+		return err
+	}
+	c.storeSymbol(c.symbolTable.Define(name))
+	return nil
+}
+
+func (c *Compiler) defineConst(name string, pos token.Position) error {
+	err := c.checkRedeclaration(name)
+	if err != nil {
+		return err
+	}
+	c.storeSymbol(c.symbolTable.DefineConst(name, pos))
+	return nil
+}
+
+func (c *Compiler) checkRedeclaration(name string) error {
+	if symbol, ok := c.symbolTable.declaredConst(name); ok {
+		return fmt.Errorf("Cannot redeclare const %s declared at %s", name, symbol.Declared)
+	}
+	return nil
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// loadCell pushes what a closure captures for s. Locals and free variables
+// are shared through a cell so assignments on either side are seen by both.
+func (c *Compiler) loadCell(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpGetLocalCell, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFreeCell, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+// compoundOps maps the operator of a compound assignment to its opcode.
+var compoundOps = map[string]code.OpCode{
+	"+=": code.OpAdd,
+	"-=": code.OpSub,
+	"*=": code.OpMul,
+	"/=": code.OpDiv,
+	"%=": code.OpMod,
+	"**=": code.OpPow,
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		return c.compileIdentifierAssignment(target, node)
+	case *ast.IndexExpression:
+		return c.compileIndexAssignment(target, node)
+	default:
+		return fmt.Errorf("Invalid assignment target: %s", node.Target.String())
+	}
+}
+
+func (c *Compiler) compileIdentifierAssignment(ident *ast.Identifier, node *ast.AssignExpression) error {
+	symbol, ok := c.symbolTable.Resolve(ident.Val)
+	if !ok {
+		return fmt.Errorf("Assignment to undefined variable: %s", ident.Val)
+	}
+	if symbol.Scope == BuiltinScope {
+		return fmt.Errorf("Cannot assign to builtin: %s", ident.Val)
+	}
+	if symbol.Const {
+		return fmt.Errorf("Cannot assign to const %s declared at %s", ident.Val, symbol.Declared)
+	}
+	if node.Op != "=" {
+		c.loadSymbol(symbol)
+	}
+	err := c.compileAssignedValue(node)
+	if err != nil {
+		return err
+	}
+	c.storeSymbol(symbol)
+	// The assignment evaluates to the stored value.
+	c.loadSymbol(symbol)
+	return nil
+}
+
+// compileIndexAssignment leaves the collection and the index on the stack
+// for OpSetIndex, a compound assignment reads the element through copies of
+// them so neither is evaluated twice.
+func (c *Compiler) compileIndexAssignment(target *ast.IndexExpression, node *ast.AssignExpression) error {
+	err := c.Compile(target.Left)
+	if err != nil {
+		return err
+	}
+	err = c.Compile(target.Index)
+	if err != nil {
+		return err
+	}
+	if node.Op != "=" {
+		c.emit(code.OpDup2)
+		c.emit(code.OpIndex)
+	}
+	err = c.compileAssignedValue(node)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpSetIndex)
+	return nil
+}
+
+// compileAssignedValue compiles the value of an assignment, for a compound
+// one the current value of the target is already on the stack.
+func (c *Compiler) compileAssignedValue(node *ast.AssignExpression) error {
+	err := c.Compile(node.Val)
+	if err != nil {
+		return err
+	}
+	if node.Op != "=" {
+		op, ok := compoundOps[node.Op]
+		if !ok {
+			return fmt.Errorf("Unknown operator %s", node.Op)
+		}
+		c.emit(op)
+	}
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) compileDictLiteral(node *ast.DictLiteral) error {
+	// Pairs is a Go map, sort the keys so the emitted instructions are the
+	// same on every compilation.
+	keys := []ast.Expression{}
+	for k := range node.Pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, k := range keys {
+		err := c.Compile(k)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Pairs[k])
+		if err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpDict, len(node.Pairs) * 2)
+	return nil
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	if node.Op == "&&" || node.Op == "||" {
+		return c.compileLogicalExpression(node)
+	}
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+	switch node.Op {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case "**":
+		c.emit(code.OpPow)
+	case "<":
+		c.emit(code.OpLessThan)
+	case "<=":
+		c.emit(code.OpLessEqual)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case ">=":
+		c.emit(code.OpGreaterEqual)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	default:
+		return fmt.Errorf("Unknown operator %s", node.Op)
+	}
+	return nil
+}
+
+// compileLogicalExpression jumps over the right side when the left side
+// decides the result. The right side is turned into a boolean with a double
+// bang so both paths leave TRUE or FALSE on the stack.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if node.Op == "&&" {
+		err = c.compileTruthiness(node.Right)
+		if err != nil {
+			return err
+		}
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	}
+	c.emit(code.OpTrue)
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	err = c.compileTruthiness(node.Right)
+	if err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileTruthiness(node ast.Expression) error {
+	err := c.Compile(node)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if condition, ok := c.foldConstant(node.Condition); ok {
+		return c.compileConstantIfExpression(node, condition)
+	}
+	err := c.Compile(node.Condition)
+	if err != nil {
+		return err
+	}
+	// The jump targets are unknown until the blocks are compiled, emit
+	// placeholders and back-patch them afterwards.
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	err = c.compileBlockValue(node.Consequence)
+	if err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		err = c.compileBlockValue(node.Alternative)
+		if err != nil {
+			return err
+		}
+	}
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+	return nil
+}
+
+// compileMatchExpression keeps the subject on the stack while the arms are
+// tried. Each check of a pattern works on a copy of the subject and jumps to
+// the next arm when it fails, so every arm starts with just the subject on
+// the stack. Names are only bound once all checks of a pattern passed.
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	err := c.Compile(node.Subject)
+	if err != nil {
+		return err
+	}
+	endJumps := []int{}
+	for _, arm := range node.Arms {
+		failJumps := []int{}
+		// The names an arm binds are only visible in its guard and body.
+		c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+		err = c.compileMatchArm(arm, &failJumps)
+		c.symbolTable = c.symbolTable.Outer
+		if err != nil {
+			return err
+		}
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+		nextArmPos := len(c.currentInstructions())
+		for _, pos := range failJumps {
+			c.changeOperand(pos, nextArmPos)
+		}
+	}
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+	afterMatchPos := len(c.currentInstructions())
+	for _, pos := range endJumps {
+		c.changeOperand(pos, afterMatchPos)
+	}
 	return nil
 }
 
+// compileMatchArm emits the checks, bindings and guard of arm, failing any
+// of them jumps to one of failJumps. The body follows with the subject
+// popped.
+func (c *Compiler) compileMatchArm(arm *ast.MatchArm, failJumps *[]int) error {
+	c.compilePatternChecks(arm.Pattern, nil, failJumps)
+	c.compilePatternBindings(arm.Pattern, nil)
+	if arm.Guard != nil {
+		err := c.Compile(arm.Guard)
+		if err != nil {
+			return err
+		}
+		*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+	}
+	c.emit(code.OpPop)
+	return c.Compile(arm.Body)
+}
+
+// compilePatternChecks emits the checks of pattern against the part of the
+// subject at path, the array indexes and dict keys leading to it.
+func (c *Compiler) compilePatternChecks(pattern ast.Pattern, path []object.Object, failJumps *[]int) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		c.loadPatternValue(path)
+		c.emitConstant(patternLiteral(pattern))
+		c.emit(code.OpEqual)
+		*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+	case *ast.ArrayPattern:
+		c.loadPatternValue(path)
+		c.emit(code.OpMatchArray, len(pattern.Elements))
+		*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		for i, el := range pattern.Elements {
+			c.compilePatternChecks(el, subPath(path, &object.Integer{Val: int64(i)}), failJumps)
+		}
+	case *ast.DictPattern:
+		c.loadPatternValue(path)
+		c.emit(code.OpMatchDict)
+		*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		for i, key := range pattern.Keys {
+			keyVal := patternLiteral(key)
+			c.loadPatternValue(path)
+			c.emitConstant(keyVal)
+			c.emit(code.OpMatchKey)
+			*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+			c.compilePatternChecks(pattern.Vals[i], subPath(path, keyVal), failJumps)
+		}
+	}
+}
+
+func (c *Compiler) compilePatternBindings(pattern ast.Pattern, path []object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		c.loadPatternValue(path)
+		c.bindPatternName(pattern.Name.Val)
+	case *ast.ArrayPattern:
+		for i, el := range pattern.Elements {
+			c.compilePatternBindings(el, subPath(path, &object.Integer{Val: int64(i)}))
+		}
+	case *ast.DictPattern:
+		for i, key := range pattern.Keys {
+			c.compilePatternBindings(pattern.Vals[i], subPath(path, patternLiteral(key)))
+		}
+	}
+}
+
+// bindPatternName stores the value on top of the stack in name, in the
+// table of the arm. Every time the arm matches it binds a new variable, a
+// closure made the last time keeps its own.
+func (c *Compiler) bindPatternName(name string) {
+	symbol := c.symbolTable.Define(name)
+	if symbol.Scope == LocalScope {
+		c.emit(code.OpBindLocal, symbol.Index)
+		return
+	}
+	c.storeSymbol(symbol)
+}
+
+// loadPatternValue pushes the part of the subject at path.
+func (c *Compiler) loadPatternValue(path []object.Object) {
+	c.emit(code.OpDup)
+	for _, step := range path {
+		c.emitConstant(step)
+		c.emit(code.OpIndex)
+	}
+}
+
+func subPath(path []object.Object, step object.Object) []object.Object {
+	return append(path[:len(path):len(path)], step)
+}
+
+// patternLiteral is the value of a literal pattern, it is computed even when
+// constant folding is disabled.
+func patternLiteral(pattern *ast.LiteralPattern) object.Object {
+	return evaluator.Eval(pattern.Val, object.NewEnvironment())
+}
+
+// compileConstantIfExpression only compiles the branch a constant condition
+// selects, the other one can never run.
+func (c *Compiler) compileConstantIfExpression(node *ast.IfExpression, condition object.Object) error {
+	if isTruthy(condition) {
+		return c.compileBlockValue(node.Consequence)
+	}
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+		return nil
+	}
+	return c.compileBlockValue(node.Alternative)
+}
+
+// compileBlockValue compiles a block so that it leaves exactly one value on
+// the stack, the value of its last expression or null.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	startPos := len(c.currentInstructions())
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+	if len(c.currentInstructions()) > startPos && c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	condition, constant := c.foldConstant(node.Condition)
+	if constant && !isTruthy(condition) {
+		// The body can never run.
+		c.emit(code.OpNull)
+		c.emit(code.OpPop)
+		return nil
+	}
+	l := &loop{start: len(c.currentInstructions())}
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, l)
+	jumpNotTruthyPos := -1
+	if !constant {
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		jumpNotTruthyPos = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+	err := c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, l.start)
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops) - 1]
+	afterLoopPos := len(c.currentInstructions())
+	if jumpNotTruthyPos >= 0 {
+		c.changeOperand(jumpNotTruthyPos, afterLoopPos)
+	}
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, afterLoopPos)
+	}
+	// Like the evaluator, a loop statement has the value null.
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileForStatement keeps the iterator on the stack while the loop runs,
+// every way out of the loop goes through the OpPop that removes it.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	err := c.Compile(node.Iterable)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	numVars := 1
+	if node.Key != nil {
+		numVars = 2
+	}
+	l := &loop{start: len(c.currentInstructions())}
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, l)
+	iterNextPos := c.emit(code.OpIterNext, 9999, numVars)
+	// The value is pushed last.
+	err = c.defineSymbol(node.Value.Val)
+	if err != nil {
+		return err
+	}
+	if node.Key != nil {
+		err = c.defineSymbol(node.Key.Val)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, l.start)
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops) - 1]
+	afterLoopPos := len(c.currentInstructions())
+	c.replaceInstruction(iterNextPos, code.Make(code.OpIterNext, afterLoopPos, numVars))
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, afterLoopPos)
+	}
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops) - 1]
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.OpCode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions: code.Instructions{},
+		lastInstruction: EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+	if c.optimize {
+		optimizeJumps(instructions)
+	}
+	c.scopes = c.scopes[:len(c.scopes) - 1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions
+}
+
+func (c *Compiler) setLastInstruction(op code.OpCode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{OpCode: op, Position: pos}
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.OpCode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.OpCode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+	positions := c.scopes[c.scopeIndex].positions
+	for len(positions) > 0 && positions[len(positions) - 1].Offset >= last.Position {
+		positions = positions[:len(positions) - 1]
+	}
+	c.scopes[c.scopeIndex].positions = positions
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.OpCode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos + i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.OpCode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	c.addPosition(posNewInstruction)
+	return posNewInstruction
+}
+
+// addPosition records that the instruction at offset was compiled from the
+// node at c.pos, unless the previous instruction already was.
+func (c *Compiler) addPosition(offset int) {
+	positions := c.scopes[c.scopeIndex].positions
+	if len(positions) > 0 && positions[len(positions) - 1].Pos == c.pos {
+		return
+	}
+	c.scopes[c.scopeIndex].positions = append(positions, code.Position{Offset: offset, Pos: c.pos})
+}
+
 func (c *Compiler) ByteCode() *ByteCode {
+	if c.optimize {
+		optimizeJumps(c.currentInstructions())
+	}
 	return &ByteCode{
-		Instructions: c.Instructions,
+		Instructions: c.currentInstructions(),
+		Positions: c.scopes[c.scopeIndex].positions,
 		Constants: c.constants,
 	}
 }
 
 type ByteCode struct {
 	Instructions code.Instructions
+	Positions code.Positions // Source positions of Instructions
 	Constants []object.Object
-}
\ No newline at end of file
+}
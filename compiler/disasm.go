@@ -0,0 +1,45 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"banana/object"
+)
+
+// Disassemble renders the main instructions followed by the constant pool.
+// Compiled functions in the pool are disassembled as well, indented below
+// their constant index.
+func (b *ByteCode) Disassemble() string {
+	var out bytes.Buffer
+	out.WriteString("main:\n")
+	out.WriteString(b.Instructions.String())
+	if len(b.Constants) == 0 {
+		return out.String()
+	}
+	out.WriteString("constants:\n")
+	for i, constant := range b.Constants {
+		switch constant := constant.(type) {
+		case *object.CompiledFunction:
+			fmt.Fprintf(&out, "%04d %s params=%d locals=%d\n", i, constant.Type(), constant.NumParameters, constant.NumLocals)
+			out.WriteString(indent(constant.Instructions.String(), "     "))
+		case *object.String:
+			fmt.Fprintf(&out, "%04d %s %q\n", i, constant.Type(), constant.Val)
+		default:
+			fmt.Fprintf(&out, "%04d %s %s\n", i, constant.Type(), constant.Inspect())
+		}
+	}
+	return out.String()
+}
+
+func indent(s string, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	var out bytes.Buffer
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		out.WriteString(prefix + line)
+	}
+	return out.String()
+}
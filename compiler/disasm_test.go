@@ -0,0 +1,33 @@
+package compiler
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	input := `let add = fn(a, b) { a + b }; add(1, "two");`
+	expected := `main:
+0000 OpClosure 0 0
+0004 OpSetGlobal 0
+0007 OpGetGlobal 0
+0010 OpConstant 1
+0013 OpConstant 2
+0016 OpCall 2
+0018 OpPop
+constants:
+0000 COMPILED_FUNCTION params=2 locals=2
+     0000 OpGetLocal 0
+     0002 OpGetLocal 1
+     0004 OpAdd
+     0005 OpReturnValue
+0001 INTEGER 1
+0002 STRING "two"
+`
+	compiler := New()
+	err := compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+	disassembled := compiler.ByteCode().Disassemble()
+	if disassembled != expected {
+		t.Errorf("Wrong disassembly, expected=\n%s\ngot=\n%s", expected, disassembled)
+	}
+}
@@ -0,0 +1,106 @@
+package compiler
+
+import (
+	"banana/ast"
+	"banana/code"
+	"banana/evaluator"
+	"banana/object"
+)
+
+// DisableOptimizations turns off constant folding, dead branch removal and
+// the peephole pass so the emitted bytecode mirrors the source one to one.
+func (c *Compiler) DisableOptimizations() {
+	c.optimize = false
+}
+
+// foldConstant evaluates an infix or prefix expression made up only of
+// literals. Expressions that produce an error are left for the runtime to
+// report. Folding is checked so an overflow is left to the runtime as well,
+// whether it wraps around depends on how the VM is run.
+func (c *Compiler) foldConstant(node ast.Expression) (object.Object, bool) {
+	if !c.optimize || !isConstantExpression(node) {
+		return nil, false
+	}
+	env := object.NewEnvironment()
+	env.EnableCheckedArithmetic()
+	res := evaluator.Eval(node, env)
+	switch res.(type) {
+	case *object.Integer, *object.Float, *object.Boolean, *object.String:
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+func isConstantExpression(node ast.Expression) bool {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean, *ast.StringLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstantExpression(node.Right)
+	case *ast.InfixExpression:
+		return isConstantExpression(node.Left) && isConstantExpression(node.Right)
+	default:
+		return false
+	}
+}
+
+func (c *Compiler) emitConstant(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		if obj.Val {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	default:
+		c.emit(code.OpConstant, c.addConstant(obj))
+	}
+}
+
+// isTruthy mirrors the truthiness rules of the evaluator and the VM.
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case evaluator.NULL, evaluator.FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+// optimizeJumps retargets jumps that land on an unconditional jump straight
+// to its final destination. Operands are patched in place so no offsets
+// move.
+func optimizeJumps(ins code.Instructions) {
+	for i := 0; i < len(ins); {
+		op := code.OpCode(ins[i])
+		def, err := code.LookUp(ins[i])
+		if err != nil {
+			return
+		}
+		if op == code.OpJump || op == code.OpJumpNotTruthy {
+			target := int(code.ReadUint16(ins[i + 1:]))
+			final := finalJumpTarget(ins, target)
+			if final != target {
+				copy(ins[i:], code.Make(op, final))
+			}
+		}
+		_, read := code.ReadOperands(def, ins[i + 1:])
+		i += 1 + read
+	}
+}
+
+func finalJumpTarget(ins code.Instructions, target int) int {
+	// Bounded by the number of instructions so a jump cycle can't hang us.
+	for hops := 0; hops < len(ins); hops++ {
+		if target >= len(ins) || code.OpCode(ins[target]) != code.OpJump {
+			return target
+		}
+		next := int(code.ReadUint16(ins[target + 1:]))
+		if next == target {
+			return target
+		}
+		target = next
+	}
+	return target
+}
@@ -0,0 +1,190 @@
+package compiler
+
+import (
+	"testing"
+	"banana/code"
+)
+
+func TestConstantFolding(t *testing.T) {
+	tests := []compilerTestCase {
+		{
+			input: "2 * 60 * 60",
+			expectedConstants: []interface{}{7200},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "-5 + 2",
+			expectedConstants: []interface{}{-3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `"ban" + "ana"`,
+			expectedConstants: []interface{}{"banana"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "!(1 < 2)",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpFalse),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let x = 2; x * (3 + 4)",
+			expectedConstants: []interface{}{2, 7},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// Errors are left for the VM to report at runtime.
+			input: "5 + true",
+			expectedConstants: []interface{}{5},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpTrue),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "1 / 0",
+			expectedConstants: []interface{}{1, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDiv),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// Whether this overflows is up to the VM.
+			input: "9223372036854775807 + 1",
+			expectedConstants: []interface{}{9223372036854775807, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runOptimizedCompilerTests(t, tests)
+}
+
+func TestDeadBranchElimination(t *testing.T) {
+	tests := []compilerTestCase {
+		{
+			input: "if (true) { 10 } else { 20 }",
+			expectedConstants: []interface{}{10},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "if (1 > 2) { 10 } else { 20 }",
+			expectedConstants: []interface{}{20},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "if (false) { undefinedName }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runOptimizedCompilerTests(t, tests)
+}
+
+func TestOptimizeJumps(t *testing.T) {
+	tests := []struct {
+		input []code.Instructions
+		expected []code.Instructions
+	} {
+		{
+			[]code.Instructions{
+				code.Make(code.OpJump, 3),
+				code.Make(code.OpJump, 6),
+				code.Make(code.OpJump, 9),
+				code.Make(code.OpNull),
+			},
+			[]code.Instructions{
+				code.Make(code.OpJump, 9),
+				code.Make(code.OpJump, 9),
+				code.Make(code.OpJump, 9),
+				code.Make(code.OpNull),
+			},
+		},
+		{
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 4),
+				code.Make(code.OpJump, 7),
+				code.Make(code.OpNull),
+			},
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 7),
+				code.Make(code.OpJump, 7),
+				code.Make(code.OpNull),
+			},
+		},
+		{
+			[]code.Instructions{
+				code.Make(code.OpJump, 0),
+			},
+			[]code.Instructions{
+				code.Make(code.OpJump, 0),
+			},
+		},
+	}
+	for _, tt := range tests {
+		ins := concatInstructions(tt.input)
+		optimizeJumps(ins)
+		err := testInstructions(tt.expected, ins)
+		if err != nil {
+			t.Errorf("optimizeJumps failed: %s", err)
+		}
+	}
+}
+
+func runOptimizedCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		byteCode := compiler.ByteCode()
+		err = testInstructions(tt.expectedInstructions, byteCode.Instructions)
+		if err != nil {
+			t.Fatalf("testInstructions failed for %q: %s", tt.input, err)
+		}
+		err = testConstants(t, tt.expectedConstants, byteCode.Constants)
+		if err != nil {
+			t.Fatalf("testConstants failed for %q: %s", tt.input, err)
+		}
+	}
+}
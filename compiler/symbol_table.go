@@ -0,0 +1,162 @@
+package compiler
+
+import "banana/token"
+
+type SymbolScope string
+
+const (
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope SymbolScope = "LOCAL"
+)
+
+type Symbol struct {
+	Name string
+	Scope SymbolScope
+	Index int
+	Const bool
+	Declared token.Position // Only set for a const, where it is declared
+	block bool // Bound in a block table
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	numDefinitions int
+
+	FreeSymbols []Symbol
+
+	block bool
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NewBlockSymbolTable returns a table for the names a match arm binds. They
+// are only visible inside the arm but take their slots from the enclosing
+// function, or from the globals at the top level.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+// Clone returns a copy of the table that later definitions don't touch, the
+// REPL keeps one to forget the names of a line that failed.
+func (s *SymbolTable) Clone() *SymbolTable {
+	clone := &SymbolTable{Outer: s.Outer, numDefinitions: s.numDefinitions, block: s.block}
+	clone.store = make(map[string]Symbol, len(s.store))
+	for name, symbol := range s.store {
+		clone.store[name] = symbol
+	}
+	clone.FreeSymbols = append([]Symbol{}, s.FreeSymbols...)
+	return clone
+}
+
+// Define binds name in this table. A name the table already holds as a
+// global or local keeps its slot, closures that captured it then see the
+// new value like they do in the evaluator.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		symbol.Const = false
+		symbol.Declared = token.Position{}
+		s.store[name] = symbol
+		return symbol
+	}
+	return s.defineSlot(name)
+}
+
+// defineSlot always gives name a new slot, parameters need one each even
+// when two share a name.
+func (s *SymbolTable) defineSlot(name string) Symbol {
+	owner := s
+	for owner.block {
+		owner = owner.Outer
+	}
+	symbol := Symbol{Name: name, Index: owner.numDefinitions, block: s.block}
+	if owner.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+	s.store[name] = symbol
+	owner.numDefinitions++
+	return symbol
+}
+
+func (s *SymbolTable) DefineConst(name string, pos token.Position) Symbol {
+	symbol := s.Define(name)
+	symbol.Const = true
+	symbol.Declared = pos
+	s.store[name] = symbol
+	return symbol
+}
+
+// declaredConst returns the const bound to name in this table itself, not
+// in an enclosing one.
+func (s *SymbolTable) declaredConst(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok || !symbol.Const || symbol.Scope == FreeScope {
+		return Symbol{}, false
+	}
+	return symbol, true
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BuiltinScope, Index: index}
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineFunctionName lets a function literal bound by const refer to itself
+// without capturing the binding that is still being defined, declared is
+// where the const is declared.
+func (s *SymbolTable) DefineFunctionName(name string, declared token.Position) Symbol {
+	symbol := Symbol{Name: name, Scope: FunctionScope, Index: 0, Const: true, Declared: declared}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+	symbol := Symbol{
+		Name: original.Name,
+		Scope: FreeScope,
+		Index: len(s.FreeSymbols) - 1,
+		Const: original.Const,
+		Declared: original.Declared,
+	}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// Resolve looks name up in this table and the ones around it. A name of an
+// enclosing function becomes a free variable, so does a global bound by a
+// match arm: the arm may run again and rebind it before the closure is
+// called.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok || s.block {
+			return symbol, ok
+		}
+		if symbol.Scope == BuiltinScope || (symbol.Scope == GlobalScope && !symbol.block) {
+			return symbol, ok
+		}
+		return s.defineFree(symbol), true
+	}
+	return symbol, ok
+}
@@ -0,0 +1,199 @@
+package compiler
+
+import (
+	"testing"
+	"banana/token"
+)
+
+func TestResolveGlobal(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		Symbol{Name: "b", Scope: GlobalScope, Index: 1},
+	}
+	for _, sym := range expected {
+		result, ok := global.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("Name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("Expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+}
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		"b": Symbol{Name: "b", Scope: GlobalScope, Index: 1},
+	}
+	global := NewSymbolTable()
+	a := global.Define("a")
+	if a != expected["a"] {
+		t.Errorf("Expected a=%+v, got=%+v", expected["a"], a)
+	}
+	b := global.Define("b")
+	if b != expected["b"] {
+		t.Errorf("Expected b=%+v, got=%+v", expected["b"], b)
+	}
+}
+
+func TestRedefineKeepsSlot(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("Redefined a got a new slot, got=%+v", a)
+	}
+	l := global.Define("len")
+	if l != (Symbol{Name: "len", Scope: GlobalScope, Index: 1}) {
+		t.Errorf("Defining over a builtin did not take a new slot, got=%+v", l)
+	}
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("b")
+	local := NewEnclosedSymbolTable(outer)
+	local.Resolve("b")
+	b := local.Define("b")
+	if b != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) {
+		t.Errorf("Defining over a free variable did not take a local slot, got=%+v", b)
+	}
+}
+
+func TestDefineAndResolveFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	declared := token.Position{Line: 1, Column: 1}
+	global.DefineFunctionName("a", declared)
+	expected := Symbol{Name: "a", Scope: FunctionScope, Index: 0, Const: true, Declared: declared}
+	result, ok := global.Resolve(expected.Name)
+	if !ok {
+		t.Fatalf("Function name %s not resolvable", expected.Name)
+	}
+	if result != expected {
+		t.Errorf("Expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	block := NewBlockSymbolTable(global)
+	b := block.Define("b")
+	if b != (Symbol{Name: "b", Scope: GlobalScope, Index: 1, block: true}) {
+		t.Errorf("Block did not take a global slot, got=%+v", b)
+	}
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("Name of a block resolvable outside of it")
+	}
+	if c := global.Define("c"); c.Index != 2 {
+		t.Errorf("Slot of a block reused, got=%+v", c)
+	}
+	// Closures capture the value of a global bound in a block.
+	local := NewEnclosedSymbolTable(block)
+	if s, _ := local.Resolve("b"); s.Scope != FreeScope {
+		t.Errorf("Global of a block not free in a closure, got=%+v", s)
+	}
+	if s, _ := local.Resolve("a"); s.Scope != GlobalScope {
+		t.Errorf("Global resolved as %+v", s)
+	}
+
+	fn := NewEnclosedSymbolTable(global)
+	fn.Define("x")
+	fnBlock := NewBlockSymbolTable(fn)
+	if y := fnBlock.Define("y"); y != (Symbol{Name: "y", Scope: LocalScope, Index: 1, block: true}) {
+		t.Errorf("Block did not take a local slot, got=%+v", y)
+	}
+	if x, _ := fnBlock.Resolve("x"); x != (Symbol{Name: "x", Scope: LocalScope, Index: 0}) {
+		t.Errorf("Local of the function resolved as %+v", x)
+	}
+	if fn.numDefinitions != 2 {
+		t.Errorf("Function has %d locals, expected 2", fn.numDefinitions)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		Symbol{Name: "c", Scope: FreeScope, Index: 0},
+		Symbol{Name: "e", Scope: LocalScope, Index: 0},
+	}
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("Name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("Expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+	expectedFree := []Symbol{
+		Symbol{Name: "c", Scope: LocalScope, Index: 0},
+	}
+	if len(secondLocal.FreeSymbols) != len(expectedFree) {
+		t.Fatalf("Wrong number of free symbols, got=%d", len(secondLocal.FreeSymbols))
+	}
+	for i, sym := range expectedFree {
+		if secondLocal.FreeSymbols[i] != sym {
+			t.Errorf("Wrong free symbol, expected=%+v, got=%+v", sym, secondLocal.FreeSymbols[i])
+		}
+	}
+	_, ok := secondLocal.Resolve("f")
+	if ok {
+		t.Errorf("Name f resolved, but was expected not to")
+	}
+}
+
+func TestDefineResolveBuiltins(t *testing.T) {
+	global := NewSymbolTable()
+	firstLocal := NewEnclosedSymbolTable(global)
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: BuiltinScope, Index: 0},
+		Symbol{Name: "b", Scope: BuiltinScope, Index: 1},
+	}
+	for i, v := range expected {
+		global.DefineBuiltin(i, v.Name)
+	}
+	for _, table := range []*SymbolTable{global, firstLocal} {
+		for _, sym := range expected {
+			result, ok := table.Resolve(sym.Name)
+			if !ok {
+				t.Errorf("Name %s not resolvable", sym.Name)
+				continue
+			}
+			if result != sym {
+				t.Errorf("Expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+			}
+		}
+	}
+}
+
+func TestResolveLocal(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		Symbol{Name: "c", Scope: LocalScope, Index: 0},
+	}
+	for _, sym := range expected {
+		result, ok := local.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("Name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("Expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+}
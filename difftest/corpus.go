@@ -0,0 +1,231 @@
+package difftest
+
+// Corpus holds programs both engines must agree on. It was seeded from the
+// evaluator tests, add a case here whenever the engines are found to differ.
+var Corpus = []string{
+	// Integers
+	"5",
+	"-10",
+	"5 + 5 + 5 + 5 - 10",
+	"2 * 2 * 2 * 2 * 2",
+	"-50 + 100 + -50",
+	"5 * 2 + 10",
+	"20 + 2 * -10",
+	"50 / 2 * 2 + 10",
+	"3 * (3 * 3) + 10",
+	"(5 + 10 * 2 + 15 / 3) * 2 + -10",
+
+	// Unicode
+	`len("バナナ🍌")`,
+	`"naïve"[2]`,
+	`"abc"[5]`,
+	`bytes("é")`,
+	`let café = "☕"; café + café`,
+
+	// Floats
+	"3.14",
+	"-.5",
+	"1 + 0.5",
+	"7 / 2.0",
+	"1e-9 * 1e9",
+	"1 == 1.0",
+	"2.5 > 3",
+	"floor(2.7) + ceil(2.2)",
+	"int(3.9) + abs(-1.5)",
+	"{1: 10, 2.5: 20}[1.0]",
+
+	// Booleans
+	"true",
+	"false",
+	"1 < 2",
+	"1 > 1",
+	"1 == 1",
+	"1 != 2",
+	"true == false",
+	"false != true",
+	"(1 < 2) == true",
+	"(1 > 2) == false",
+	"!true",
+	"!5",
+	"!!false",
+	"!!5",
+	"1 <= 2",
+	"3 >= 4",
+	`"apple" < "banana"`,
+	`"a" == "a"`,
+	"7 % 3 + 2 ** 10",
+	"-2 ** 2",
+	"2 ** -2",
+	`"ab" * 3`,
+	`"ab" * -1`,
+	"1 / 0",
+	"let zero = 0; 7 % zero",
+	"1.5 / 0",
+	"true && false",
+	"1 && 0",
+	"false || 2 > 1",
+	"let f = fn() { [][0] + 1 }; false && f()",
+	"let f = fn() { [][0] + 1 }; true || f()",
+	"let f = fn() { [][0] + 1 }; true && f()",
+
+	// Conditionals
+	"if (true) { 10 }",
+	"if (false) { 10 }",
+	"if (1) { 10 }",
+	"if (1 > 2) { 10 } else { 20 }",
+	"if (1 < 2) { 10 } else { 20 }",
+
+	// Return statements
+	"return 10;",
+	"return 10; 9;",
+	"9; return 2 * 5; 9;",
+	"if (10 > 1) { if (10 > 1) { return 10; } return 1; }",
+
+	// Errors
+	"5 + true;",
+	"5 + true; 5",
+	"-true;",
+	"true + false;",
+	"5; true + false; 5",
+	"if (10 > 1) { true + false; }",
+	"foobar",
+	`"hello" - "world"`,
+	"fn(a, b) { a + b; }(1)",
+	`len(1)`,
+	`len("one", "two")`,
+
+	// Bindings
+	"let a = 5; a;",
+	"let a = 5 * 5; a;",
+	"let a = 5; let b = a; b;",
+	"let a = 5; let b = a; let c = a + b + 5; c",
+
+	// Comparisons evaluate left to right and report the operator as written
+	"let x = 1; let r = (x += 1) < (x *= 3); [r, x]",
+	"let x = 1; let r = (x += 1) <= (x *= 3); [r, x]",
+	`1 < "a"`,
+	"true < false",
+	"true <= false",
+
+	// Functions
+	"let x = 1; let f = fn() { x }; let x = 2; f()",
+	"let g = fn() { let x = 1; let f = fn() { x }; let x = 2; f() }; g()",
+	"fn(x) { x + 2; };",
+	"let identity = fn(x) { x; }; identity(5);",
+	"let identity = fn(x) { return x; }; identity(5);",
+	"let double = fn(x) { x * 2; }; double(5);",
+	"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));",
+	"fn(x) { x; }(5)",
+	"let newAdder = fn(x) { fn(y) { x + y }; }; let addTwo = newAdder(2); addTwo(2);",
+	"let fib = fn(x) { if (x < 2) { return x; } fib(x - 1) + fib(x - 2) }; fib(10)",
+	"let counter = fn() { counter = 5; 1 }; counter(); counter",
+	"let f = fn() { f }; let g = f; f = 3; g()",
+	"let f = fn() { f }; let g = f; let f = 3; g()",
+	"let h = fn() { let f = fn() { f }; let g = f; f = 3; g() }; h()",
+	"let h = fn() { let counter = fn() { counter = 5; 1 }; counter(); counter }; h()",
+	"const fib = fn(x) { if (x < 2) { return x; } fib(x - 1) + fib(x - 2) }; fib(10)",
+
+	// Strings
+	`"hello world!"`,
+	`"hello" + " " + "world"`,
+	`len("")`,
+	`len("hello world")`,
+
+	// Arrays
+	"[1, 2 * 2, 3 + 3]",
+	"[1, 2, 3][0]",
+	"[1, 2, 3][1 + 1]",
+	"let i = 0; [1][i]",
+	"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+	"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+	"[1, 2, 3][3]",
+	"[1, 2, 3][-1]",
+	"first([1, 2, 3])",
+	"last([1, 2, 3])",
+	"rest([1, 2, 3])",
+	"push([1, 2], 3)",
+
+	// Dicts
+	`let two = "two"; {"one": 10 - 9, two: 1 + 1, "thr" + "ee": 6 / 2, 4: 4, true: 5, false: 6}`,
+	`{"foo": 5}["foo"]`,
+	`{"foo": 5}["bar"]`,
+	`{}["foo"]`,
+
+	// Loops
+	"while (false) { 10 }",
+	"while (true) { break; }",
+	"while (true) { break; 10 }; 20",
+	"let f = fn() { while (true) { return 10; } }; f()",
+	"let f = fn() { while (true) { while (true) { break; } return 10; } }; f()",
+	"let f = fn() { while (true) { break; } }; f()",
+	"while (true) { if (true) { break; } continue; }",
+	"let i = 0; while (i < 5) { i += 1; let x = if (true) { break; }; }; i",
+	"while (true) { 1 + if (true) { continue; } }",
+	"let s = 0; for (x in range(0, 5000, 1)) { s + if (true) { continue; } }; s",
+	"let s = 0; for (x in range(0, 5000, 1)) { if (x % 2 == 0) { continue; } s += x; }; s",
+	"for (x in [1, 2, 3]) { x }",
+	"let f = fn(xs) { for (i, x in xs) { if (x > 1) { return i; } } }; f([1, 2, 3])",
+	`let f = fn(d) { for (k, v in d) { return [k, v]; } }; f({"b": 1, "a": 2, 3: 4, true: 5})`,
+	`let f = fn(s) { for (c in s) { if (c != "a") { return c; } } }; f("aé")`,
+	"let f = fn() { for (i in range(10, 0, -3)) { if (i < 5) { return i; } } }; f()",
+	"for (i in range(3)) { }; i",
+	"range(2, 10, 3)",
+	"for (x in 5) { x }",
+	"range(0, 1, 0)",
+
+	// Assignment
+	"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4",
+	"let a = 1; let b = 2; a = b = 3; [a, b]",
+	"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()",
+	"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n }; f()",
+	"let f = fn() { let n = 0; let g = fn() { fn() { n += 10 } }; g()(); n }; f()",
+	"let i = 0; let sum = 0; while (i < 5) { i += 1; if (i == 3) { continue; } sum += i; }; sum",
+	"let f = fn() { let i = 0; let sum = 0; while (i < 5) { i += 1; if (i == 3) { continue; } sum += i; } sum }; f()",
+	"let f = fn() { let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }) } fs[0]() }; f()",
+	"let x = 1; x += true",
+	"let x = 5; x **= 2; x",
+	"let a = [2, 3]; a[1] **= a[0]; a",
+	"let x = 2; x **= -1",
+	"y = 1",
+	"len = 1",
+	"let a = [1, 2, 3]; a[1] = 5; a",
+	"let a = [[1], [2]]; a[1][0] *= 7; a",
+	`let d = {"a": 1}; d["b"] = 2; d["a"] -= 1; d`,
+	"let i = 0; let a = [0, 0]; let next = fn() { i += 1; i - 1 }; a[next()] += 5; [a, i]",
+	"let f = fn() { let a = []; for (i in range(4)) { append!(a, i * i) } a }; f()",
+	"let a = [1, 3]; insert(a, 1, 2); insert(a, 3, 4); insert(a, 0, 0)",
+	`let a = [1, 2, 3]; let d = {"a": 1, "b": 2}; [delete(a, 1), a, delete(d, "a"), delete(d, "x"), d]`,
+	"let a = [1, 2]; a[2] = 3",
+	"let d = {}; d[[1]] = 3",
+	`let s = "abc"; s[0] = "x"`,
+	"let d = {}; d[1] += 1",
+
+	// Const
+	"const x = 2; let f = fn(y) { x * y }; f(21)",
+	"const x = 1; let f = fn() { let x = 2; x += 1; x }; [f(), x]",
+	"const x = 1; x = 2",
+	"const x = 1; let x = 2;",
+
+	// Match
+	"let grade = fn(s) { if (s >= 90) { \"A\" } else if (s >= 80) { \"B\" } else { \"F\" } }; [grade(95), grade(85), grade(10)]",
+	"let f = fn(v) { match (v) { 0 => \"zero\", -1.5 => \"neg\", [a, b] if a == b => a, [_, [c, _]] => c, {\"k\": k} => k, n if n > 10 => \"big\", _ => \"other\" } }; [f(0), f(-1.5), f([2, 2]), f([1, [7, 8]]), f({\"k\": 3}), f(11), f(5)]",
+	"let x = 1; match ([2, 3]) { [x, 4] => x }; x",
+	"let x = 1; match ([2]) { [x] => x }; x",
+	"match (3) { 1 => 2 }",
+	"match (\"a\") { n if n > 1 => n }",
+	"match (1) { [x] => 0, _ => x + 1 }",
+	"match (1) { [x] => 0, _ => x }",
+	"let x = 1; match (5) { x if x > 10 => 0, _ => 0 }; x",
+	"let x = 1; match (5) { x => x = 7 }; x",
+	"let f = fn() { match (1) { [x] => 0, _ => x } }; f()",
+	"let fs = []; for (v in [1, 2]) { fs = push(fs, match (v) { n => fn() { n } }) }; [fs[0](), fs[1]()]",
+	"let f = fn() { let fs = []; for (v in [1, 2]) { fs = push(fs, match (v) { n => fn() { n } }) }; [fs[0](), fs[1]()] }; f()",
+	"let f = fn() { match (1) { n => if (true) { let g = fn() { n += 1 }; g(); n } } }; f()",
+
+	// Macros
+	"let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);",
+	`let unless = macro(condition, consequence, alternative) {
+		quote(if (!(unquote(condition))) { unquote(consequence); } else { unquote(alternative); });
+	};
+	unless(10 > 5, 1, 2);`,
+}
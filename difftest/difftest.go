@@ -0,0 +1,163 @@
+// Package difftest runs Banana programs through both the tree-walking
+// evaluator and the bytecode VM and reports where their results disagree.
+package difftest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"banana/ast"
+	"banana/compiler"
+	"banana/evaluator"
+	"banana/lexer"
+	"banana/object"
+	"banana/parser"
+	"banana/vm"
+)
+
+type Mismatch struct {
+	Input string
+	Evaluator object.Object
+	VM object.Object
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%q\n\tevaluator: %s\n\tvm:        %s", m.Input, describe(m.Evaluator), describe(m.VM))
+}
+
+// Check runs every program of the corpus on both engines and returns the
+// ones whose results differ.
+func Check(corpus []string) []Mismatch {
+	mismatches := []Mismatch{}
+	for _, input := range corpus {
+		evaluated := RunEvaluator(input)
+		executed := RunVM(input)
+		if !Equal(evaluated, executed) {
+			mismatches = append(mismatches, Mismatch{Input: input, Evaluator: evaluated, VM: executed})
+		}
+	}
+	return mismatches
+}
+
+func RunEvaluator(input string) object.Object {
+	program, errObj := parse(input)
+	if errObj != nil {
+		return errObj
+	}
+	res := evaluator.Eval(program, object.NewEnvironment())
+	if res == nil {
+		return evaluator.NULL
+	}
+	return res
+}
+
+// RunVM compiles and runs input, compile and runtime errors are returned as
+// *object.Error so they can be compared with the evaluator's.
+func RunVM(input string) object.Object {
+	program, errObj := parse(input)
+	if errObj != nil {
+		return errObj
+	}
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		return &object.Error{Msg: err.Error()}
+	}
+	machine := vm.New(comp.ByteCode())
+	err = machine.Run()
+	if err, ok := err.(*vm.RuntimeError); ok {
+		return &object.Error{Msg: err.Msg, Pos: err.Pos}
+	}
+	if err != nil {
+		return &object.Error{Msg: err.Error()}
+	}
+	res := machine.LastPoppedStackElem()
+	if res == nil {
+		return evaluator.NULL
+	}
+	return res
+}
+
+func parse(input string) (ast.Node, *object.Error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		msgs := []string{}
+		for _, err := range p.Errors() {
+			msgs = append(msgs, err.Error())
+		}
+		return nil, &object.Error{Msg: "Parser errors: " + strings.Join(msgs, "; ")}
+	}
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	return evaluator.ExpandMacros(program, macroEnv), nil
+}
+
+// Equal compares two results structurally. Functions only compare by kind,
+// the engines represent them differently.
+func Equal(a, b object.Object) bool {
+	if isFunction(a) && isFunction(b) {
+		return true
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Val == b.(*object.Integer).Val
+	case *object.Float:
+		return a.Val == b.(*object.Float).Val || math.IsNaN(a.Val) && math.IsNaN(b.(*object.Float).Val)
+	case *object.Boolean:
+		return a.Val == b.(*object.Boolean).Val
+	case *object.String:
+		return a.Val == b.(*object.String).Val
+	case *object.Null:
+		return true
+	case *object.Error:
+		// Compile errors carry no position, only runtime errors are
+		// compared by where they were raised.
+		other := b.(*object.Error)
+		samePos := a.Pos == other.Pos || !a.Pos.IsValid() || !other.Pos.IsValid()
+		return a.Msg == other.Msg && samePos
+	case *object.Array:
+		other := b.(*object.Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], other.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Range:
+		return *a == *b.(*object.Range)
+	case *object.Dict:
+		other := b.(*object.Dict)
+		if len(a.Pairs) != len(other.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			otherPair, ok := other.Pairs[key]
+			if !ok || !Equal(pair.Val, otherPair.Val) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func isFunction(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Closure, *object.Builtin:
+		return true
+	default:
+		return false
+	}
+}
+
+func describe(obj object.Object) string {
+	return fmt.Sprintf("%s %s", obj.Type(), obj.Inspect())
+}
@@ -0,0 +1,59 @@
+package difftest
+
+import (
+	"bytes"
+	"testing"
+	"banana/compiler"
+	"banana/object"
+)
+
+func TestCorpus(t *testing.T) {
+	for _, m := range Check(Corpus) {
+		t.Errorf("Engines disagree on %s", m)
+	}
+}
+
+// Everything the compiler emits must pass the checks a bytecode file gets
+// when it is decoded.
+func TestCorpusBytecodeFiles(t *testing.T) {
+	for _, input := range Corpus {
+		program, errObj := parse(input)
+		if errObj != nil {
+			continue
+		}
+		for _, optimize := range []bool{true, false} {
+			comp := compiler.New()
+			if !optimize {
+				comp.DisableOptimizations()
+			}
+			if comp.Compile(program) != nil {
+				continue
+			}
+			var buf bytes.Buffer
+			err := comp.ByteCode().Encode(&buf)
+			if err != nil {
+				t.Fatalf("Encode error for %q: %s", input, err)
+			}
+			_, err = compiler.Decode(&buf)
+			if err != nil {
+				t.Errorf("Decode error for %q (optimize=%t): %s", input, optimize, err)
+			}
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	mismatches := Check([]string{"1 + 1", "fn() {}"})
+	if len(mismatches) != 0 {
+		t.Fatalf("Expected no mismatches, got=%d", len(mismatches))
+	}
+	if Equal(&object.Integer{Val: 1}, &object.Integer{Val: 2}) {
+		t.Errorf("Different integers compared equal")
+	}
+	if Equal(&object.Error{Msg: "a"}, &object.Error{Msg: "b"}) {
+		t.Errorf("Errors with different messages compared equal")
+	}
+	if Equal(&object.Array{Elements: []object.Object{}}, &object.Null{}) {
+		t.Errorf("Objects of different types compared equal")
+	}
+}
@@ -2,6 +2,9 @@ package evaluator
 
 import(
 	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
 	"banana/object"
 )
 
@@ -9,93 +12,356 @@ const(
 	wrongNumErr = "Wrong number of args, got=%d, expected=%d"
 )
 
-var builtins = map[string]*object.Builtin{
-	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(wrongNumErr, len(args), 1)
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("Arg to `first` must be ARRAY, got %s", args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
-			}
-			return NULL
+// Builtins is ordered so the compiler can refer to a builtin by its index.
+var Builtins = []struct {
+	Name string
+	Builtin *object.Builtin
+} {
+	{
+		"first",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError(wrongNumErr, len(args), 1)
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("Arg to `first` must be ARRAY, got %s", args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				if len(arr.Elements) > 0 {
+					return arr.Elements[0]
+				}
+				return NULL
+			},
 		},
 	},
-	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(wrongNumErr, len(args), 1)
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("Arg to `last` must be ARRAY, got %s", args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length - 1]
-			}
-			return NULL
+	{
+		"last",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError(wrongNumErr, len(args), 1)
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("Arg to `last` must be ARRAY, got %s", args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					return arr.Elements[length - 1]
+				}
+				return NULL
+			},
 		},
 	},
-	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(wrongNumErr, len(args), 1)
-			}
-			switch arg := args[0].(type) {
-			case *object.Array:
-				return &object.Integer{Val: int64(len(arg.Elements))}
-			case *object.String:
-				return &object.Integer{Val: int64(len(arg.Val))}
-			default:
-				return newError("Arg to `len` not supported, got %s", args[0].Type())
-			}
+	{
+		"len",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError(wrongNumErr, len(args), 1)
+				}
+				switch arg := args[0].(type) {
+				case *object.Array:
+					return &object.Integer{Val: int64(len(arg.Elements))}
+				case *object.String:
+					return &object.Integer{Val: int64(utf8.RuneCountInString(arg.Val))}
+				default:
+					return newError("Arg to `len` not supported, got %s", args[0].Type())
+				}
+			},
 		},
 	},
-	"print": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
-			}
-			return NULL
+	{
+		"print",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Println(arg.Inspect())
+				}
+				return NULL
+			},
 		},
 	},
-	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError(wrongNumErr, len(args), 2)
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("Arg to `push` must be ARRAY, got %s", args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length + 1, length + 1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-			return &object.Array{Elements: newElements}
+	{
+		"push",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError(wrongNumErr, len(args), 2)
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("Arg to `push` must be ARRAY, got %s", args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				newElements := make([]object.Object, length + 1, length + 1)
+				copy(newElements, arr.Elements)
+				newElements[length] = args[1]
+				return &object.Array{Elements: newElements}
+			},
 		},
 	},
-	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(wrongNumErr, len(args), 1)
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("Arg to `rest` must be ARRAY, got %s", args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length - 1, length - 1)
-				copy(newElements, arr.Elements[1: length])
-				return &object.Array{Elements: newElements}
-			}
-			return NULL
+	{
+		"rest",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError(wrongNumErr, len(args), 1)
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("Arg to `rest` must be ARRAY, got %s", args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					newElements := make([]object.Object, length - 1, length - 1)
+					copy(newElements, arr.Elements[1: length])
+					return &object.Array{Elements: newElements}
+				}
+				return NULL
+			},
+		},
+	},
+	{
+		"float",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError(wrongNumErr, len(args), 1)
+				}
+				switch arg := args[0].(type) {
+				case *object.Integer:
+					return &object.Float{Val: float64(arg.Val)}
+				case *object.Float:
+					return arg
+				case *object.String:
+					val, err := strconv.ParseFloat(arg.Val, 64)
+					if err != nil {
+						return newError("Could not convert %q to FLOAT", arg.Val)
+					}
+					return &object.Float{Val: val}
+				default:
+					return newError("Arg to `float` not supported, got %s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		"int",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError(wrongNumErr, len(args), 1)
+				}
+				switch arg := args[0].(type) {
+				case *object.Integer:
+					return arg
+				case *object.Float:
+					if math.IsNaN(arg.Val) || arg.Val < math.MinInt64 || arg.Val >= math.MaxInt64 {
+						return newError("Could not convert %s to INTEGER", arg.Inspect())
+					}
+					return &object.Integer{Val: int64(arg.Val)}
+				case *object.String:
+					val, err := strconv.ParseInt(arg.Val, 10, 64)
+					if err != nil {
+						return newError("Could not convert %q to INTEGER", arg.Val)
+					}
+					return &object.Integer{Val: val}
+				default:
+					return newError("Arg to `int` not supported, got %s", args[0].Type())
+				}
+			},
 		},
 	},
-}
\ No newline at end of file
+	{
+		"abs",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError(wrongNumErr, len(args), 1)
+				}
+				switch arg := args[0].(type) {
+				case *object.Integer:
+					// There is no positive MinInt64 to wrap around to, so
+					// this is an error even when arithmetic is unchecked.
+					if arg.Val == math.MinInt64 {
+						return newError("Integer overflow: abs(%s)", arg.Inspect())
+					}
+					if arg.Val < 0 {
+						return &object.Integer{Val: -arg.Val}
+					}
+					return arg
+				case *object.Float:
+					return &object.Float{Val: math.Abs(arg.Val)}
+				default:
+					return newError("Arg to `abs` must be INTEGER or FLOAT, got %s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		"floor",
+		&object.Builtin{Fn: floatFunction("floor", math.Floor)},
+	},
+	{
+		"ceil",
+		&object.Builtin{Fn: floatFunction("ceil", math.Ceil)},
+	},
+	{
+		"sqrt",
+		&object.Builtin{Fn: floatFunction("sqrt", math.Sqrt)},
+	},
+	{
+		"bytes",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError(wrongNumErr, len(args), 1)
+				}
+				if args[0].Type() != object.STRING_OBJ {
+					return newError("Arg to `bytes` must be STRING, got %s", args[0].Type())
+				}
+				str := args[0].(*object.String).Val
+				elements := make([]object.Object, len(str))
+				for i := 0; i < len(str); i++ {
+					elements[i] = &object.Integer{Val: int64(str[i])}
+				}
+				return &object.Array{Elements: elements}
+			},
+		},
+	},
+	{
+		"range",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) < 1 || len(args) > 3 {
+					return newError("Wrong number of args, got=%d, expected=1 to 3", len(args))
+				}
+				bounds := make([]int64, len(args))
+				for i, arg := range args {
+					integer, ok := arg.(*object.Integer)
+					if !ok {
+						return newError("Arg to `range` must be INTEGER, got %s", arg.Type())
+					}
+					bounds[i] = integer.Val
+				}
+				switch len(bounds) {
+				case 1:
+					return &object.Range{Start: 0, End: bounds[0], Step: 1}
+				case 2:
+					return &object.Range{Start: bounds[0], End: bounds[1], Step: 1}
+				}
+				if bounds[2] == 0 {
+					return newError("Range step cannot be zero")
+				}
+				return &object.Range{Start: bounds[0], End: bounds[1], Step: bounds[2]}
+			},
+		},
+	},
+	{
+		"append!",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError(wrongNumErr, len(args), 2)
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("Arg to `append!` must be ARRAY, got %s", args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				arr.Elements = append(arr.Elements, args[1])
+				return arr
+			},
+		},
+	},
+	{
+		"delete",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError(wrongNumErr, len(args), 2)
+				}
+				switch coll := args[0].(type) {
+				case *object.Array:
+					idx, ok := args[1].(*object.Integer)
+					if !ok {
+						return newError("Array index must be INTEGER, got %s", args[1].Type())
+					}
+					length := len(coll.Elements)
+					if idx.Val < 0 || idx.Val >= int64(length) {
+						return newError(indexOutOfBounds, idx.Val, length)
+					}
+					removed := coll.Elements[idx.Val]
+					copy(coll.Elements[idx.Val:], coll.Elements[idx.Val + 1:])
+					coll.Elements[length - 1] = nil
+					coll.Elements = coll.Elements[:length - 1]
+					return removed
+				case *object.Dict:
+					key, ok := args[1].(object.Hashable)
+					if !ok {
+						return newError("Unusable as dict key: %s", args[1].Type())
+					}
+					pair, ok := coll.Pairs[key.DictKey()]
+					if !ok {
+						return NULL
+					}
+					delete(coll.Pairs, key.DictKey())
+					return pair.Val
+				default:
+					return newError("Arg to `delete` must be ARRAY or DICT, got %s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		"insert",
+		&object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 3 {
+					return newError(wrongNumErr, len(args), 3)
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("Arg to `insert` must be ARRAY, got %s", args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				idx, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("Array index must be INTEGER, got %s", args[1].Type())
+				}
+				length := len(arr.Elements)
+				// Inserting at the length appends.
+				if idx.Val < 0 || idx.Val > int64(length) {
+					return newError(indexOutOfBounds, idx.Val, length)
+				}
+				arr.Elements = append(arr.Elements, nil)
+				copy(arr.Elements[idx.Val + 1:], arr.Elements[idx.Val:])
+				arr.Elements[idx.Val] = args[2]
+				return arr
+			},
+		},
+	},
+}
+
+// floatFunction wraps a one argument math function as a builtin that
+// accepts an INTEGER or a FLOAT and returns a FLOAT.
+func floatFunction(name string, fn func(float64) float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError(wrongNumErr, len(args), 1)
+		}
+		if !isNumber(args[0]) {
+			return newError("Arg to `%s` must be INTEGER or FLOAT, got %s", name, args[0].Type())
+		}
+		return &object.Float{Val: fn(toFloat(args[0]))}
+	}
+}
+
+func GetBuiltinByName(name string) *object.Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+	return nil
+}
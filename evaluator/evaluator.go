@@ -2,6 +2,8 @@ package evaluator
 
 import(
 	"fmt"
+	"math"
+	"strings"
 	"banana/ast"
 	"banana/object"
 )
@@ -10,10 +12,16 @@ var(
 	NULL = &object.Null{}
 	TRUE = &object.Boolean{Val: true}
 	FALSE = &object.Boolean{Val: false}
+	BREAK = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 const(
 	unknownInfixOp string = "Unknown operator: %s %s %s"
+	divisionByZero string = "Division by zero: %s %s %s"
+	integerOverflow string = "Integer overflow: %s %s %s"
+	negationOverflow string = "Integer overflow: -(%s)"
+	indexOutOfBounds string = "Index out of bounds: %d, length %d"
 )
 
 func isError(obj object.Object) bool {
@@ -23,27 +31,48 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// Eval evaluates node and, when the result is an error that does not know
+// where it came from yet, tags it with the position of the innermost node
+// that produced it.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := evalNode(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
 	// Statements
 	case *ast.BlockStatement:
 		return evalBlockStatements(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
 	case *ast.LetStatement:
 		val := Eval(node.Val, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Val, val)
+		if err := env.Declare(node.Name.Val, val, node.IsConst(), node.Pos()); err != nil {
+			return err
+		}
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnVal, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Val: val}
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
 	// Expressions
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
@@ -51,6 +80,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Val)
 	case *ast.CallExpression:
@@ -68,6 +99,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return applyFunction(function, args)
 	case *ast.DictLiteral:
 		return evalDictLiteral(node, env)
+	case *ast.FloatLiteral:
+		return &object.Float{Val: node.Val}
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
@@ -76,6 +109,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIdentifier(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -87,6 +122,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalIndexExpression(left, index)
 	case *ast.InfixExpression:
+		if node.Op == "&&" || node.Op == "||" {
+			return evalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -95,7 +133,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Op, left, right)
+		return evalInfixExpression(node.Op, left, right, env.CheckedArithmetic())
 	case *ast.IntegerLiteral:
 		return &object.Integer{Val: node.Val}
 	case *ast.PrefixExpression:
@@ -103,7 +141,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Op, right)
+		return evalPrefixExpression(node.Op, right, env.CheckedArithmetic())
 	case *ast.StringLiteral:
 		return &object.String{Val: node.Val}
 	}
@@ -123,6 +161,8 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 			return res.Val
 		case *object.Error:
 			return res
+		case *object.Break, *object.Continue:
+			return loopControlError(res)
 		}
 	}
 	return res
@@ -145,7 +185,8 @@ func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) obj
 		res = Eval(stmt, env)
 		if res != nil {
 			resType := res.Type()
-			if resType == object.RETURN_VALUE_OBJ || resType == object.ERROR_OBJ {
+			if resType == object.RETURN_VALUE_OBJ || resType == object.ERROR_OBJ ||
+				resType == object.BREAK_OBJ || resType == object.CONTINUE_OBJ {
 				return res
 			}
 		}
@@ -189,8 +230,15 @@ func evalDictLiteral(node *ast.DictLiteral, env *object.Environment) object.Obje
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError(wrongNumErr, len(args), len(fn.Parameters))
+		}
 		extendedEnv := extendFunctionEnv(fn, args)
 		evaluated := Eval(fn.Body, extendedEnv)
+		switch evaluated.(type) {
+		case *object.Break, *object.Continue:
+			return loopControlError(evaluated)
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
 		return fn.Fn(args...)
@@ -214,6 +262,16 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// loopControlError reports a break or continue that escaped to a function or
+// program boundary. The parser rejects those, so this only happens for
+// syntax trees built some other way, e.g. by a macro.
+func loopControlError(obj object.Object) *object.Error {
+	if obj.Type() == object.BREAK_OBJ {
+		return newError("Break outside of a loop")
+	}
+	return newError("Continue outside of a loop")
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -225,12 +283,90 @@ func evalIdentifier(i *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(i.Val); ok {
 		return val
 	}
-	if builtin, ok := builtins[i.Val]; ok {
+	if builtin := GetBuiltinByName(i.Val); builtin != nil {
 		return builtin
 	}
 	return newError("Identifier not found: " + i.Val)
 }
 
+func evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		return evalIdentifierAssignment(target, ae, env)
+	case *ast.IndexExpression:
+		return evalIndexAssignment(target, ae, env)
+	default:
+		return newError("Invalid assignment target: %s", ae.Target.String())
+	}
+}
+
+// A compound assignment reads the target before evaluating the value, the
+// same order as target + val.
+func evalIdentifierAssignment(ident *ast.Identifier, ae *ast.AssignExpression, env *object.Environment) object.Object {
+	var current object.Object
+	if ae.Op != "=" {
+		var ok bool
+		current, ok = env.Get(ident.Val)
+		if !ok {
+			return assignmentError(ident.Val)
+		}
+	}
+	val := Eval(ae.Val, env)
+	if isError(val) {
+		return val
+	}
+	if current != nil {
+		val = evalInfixExpression(strings.TrimSuffix(ae.Op, "="), current, val, env.CheckedArithmetic())
+		if isError(val) {
+			return val
+		}
+	}
+	ok, err := env.Assign(ident.Val, val)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return assignmentError(ident.Val)
+	}
+	return val
+}
+
+func evalIndexAssignment(target *ast.IndexExpression, ae *ast.AssignExpression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := Eval(target.Index, env)
+	if isError(index) {
+		return index
+	}
+	var current object.Object
+	if ae.Op != "=" {
+		current = evalIndexExpression(left, index)
+		if isError(current) {
+			return current
+		}
+	}
+	val := Eval(ae.Val, env)
+	if isError(val) {
+		return val
+	}
+	if current != nil {
+		val = evalInfixExpression(strings.TrimSuffix(ae.Op, "="), current, val, env.CheckedArithmetic())
+		if isError(val) {
+			return val
+		}
+	}
+	return SetIndex(left, index, val)
+}
+
+func assignmentError(name string) *object.Error {
+	if GetBuiltinByName(name) != nil {
+		return newError("Cannot assign to builtin: %s", name)
+	}
+	return newError("Assignment to undefined variable: %s", name)
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env) 
 	if isError(condition) {
@@ -245,6 +381,160 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+	for _, arm := range me.Arms {
+		bindings := []patternBinding{}
+		if !matchPattern(arm.Pattern, subject, env, &bindings) {
+			continue
+		}
+		// The names an arm binds are only visible in its guard and body.
+		armEnv := object.NewEnclosedEnvironment(env)
+		for _, b := range bindings {
+			armEnv.Set(b.name.Val, b.val)
+		}
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return Eval(arm.Body, armEnv)
+	}
+	return NULL
+}
+
+type patternBinding struct {
+	name *ast.Identifier
+	val object.Object
+}
+
+// matchPattern reports whether val matches pattern. The names the pattern
+// binds are collected in bindings and only bound once the whole pattern
+// matched.
+func matchPattern(pattern ast.Pattern, val object.Object, env *object.Environment, bindings *[]patternBinding) bool {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return true
+	case *ast.BindingPattern:
+		*bindings = append(*bindings, patternBinding{pattern.Name, val})
+		return true
+	case *ast.LiteralPattern:
+		return evalInfixExpression("==", val, Eval(pattern.Val, env), false) == TRUE
+	case *ast.ArrayPattern:
+		array, ok := val.(*object.Array)
+		if !ok || len(array.Elements) != len(pattern.Elements) {
+			return false
+		}
+		for i, el := range pattern.Elements {
+			if !matchPattern(el, array.Elements[i], env, bindings) {
+				return false
+			}
+		}
+		return true
+	case *ast.DictPattern:
+		if _, ok := val.(*object.Dict); !ok {
+			return false
+		}
+		for i, key := range pattern.Keys {
+			pair, ok := DictPair(val, Eval(key.Val, env))
+			if !ok || !matchPattern(pattern.Vals[i], pair.Val, env, bindings) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// DictPair looks up key in dict, ok is false when dict is not a dict or has
+// no such key.
+func DictPair(dict, key object.Object) (pair object.DictPair, ok bool) {
+	dictObject, ok := dict.(*object.Dict)
+	if !ok {
+		return object.DictPair{}, false
+	}
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return object.DictPair{}, false
+	}
+	pair, ok = dictObject.Pairs[hashable.DictKey()]
+	return pair, ok
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		cond := Eval(ws.Condition, env)
+		if isError(cond) {
+			return cond
+		}
+		if !isTruthy(cond) {
+			return NULL
+		}
+		if res, done := evalLoopBody(ws.Body, env); done {
+			return res
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	it, ok := object.Iterate(iterable)
+	if !ok {
+		return newError("Not iterable: %s", iterable.Type())
+	}
+	for {
+		if fs.Key == nil {
+			val, ok := it.NextElement()
+			if !ok {
+				return NULL
+			}
+			if err := env.Declare(fs.Value.Val, val, false, fs.Value.Pos()); err != nil {
+				return err
+			}
+		} else {
+			key, val, ok := it.Next()
+			if !ok {
+				return NULL
+			}
+			if err := env.Declare(fs.Key.Val, key, false, fs.Key.Pos()); err != nil {
+				return err
+			}
+			if err := env.Declare(fs.Value.Val, val, false, fs.Value.Pos()); err != nil {
+				return err
+			}
+		}
+		if res, done := evalLoopBody(fs.Body, env); done {
+			return res
+		}
+	}
+}
+
+// evalLoopBody runs one iteration of a loop, done reports whether the loop
+// is over and res is then what the loop evaluates to.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (res object.Object, done bool) {
+	res = Eval(body, env)
+	if res != nil {
+		switch res.Type() {
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+			return res, true
+		case object.BREAK_OBJ:
+			return NULL, true
+		}
+	}
+	return nil, false
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -262,6 +552,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.DICT_OBJ:
 		return evalDictIndexExpression(left, index)
 	default:
@@ -269,6 +561,36 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
+// SetIndex stores val in an array or dict in place and returns it.
+func SetIndex(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("Array index must be INTEGER, got %s", index.Type())
+		}
+		if idx.Val < 0 || idx.Val >= int64(len(left.Elements)) {
+			return newError(indexOutOfBounds, idx.Val, len(left.Elements))
+		}
+		left.Elements[idx.Val] = val
+	case *object.Dict:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("Unusable as dict key: %s", index.Type())
+		}
+		hashed := key.DictKey()
+		pair, ok := left.Pairs[hashed]
+		if !ok {
+			pair.Key = index
+		}
+		pair.Val = val
+		left.Pairs[hashed] = pair
+	default:
+		return newError("Index assignment not supported: %s", left.Type())
+	}
+	return val
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Val
@@ -280,6 +602,16 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression indexes by code point, not by byte.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Val)
+	idx := index.(*object.Integer).Val
+	if idx < 0 || idx >= int64(len(runes)) {
+		return NULL
+	}
+	return &object.String{Val: string(runes[idx])}
+}
+
 func evalDictIndexExpression(dict, index object.Object) object.Object {
 	dictObject := dict.(*object.Dict)
 	key, ok := index.(object.Hashable)
@@ -293,12 +625,35 @@ func evalDictIndexExpression(dict, index object.Object) object.Object {
 	return pair.Val
 }
 
-func evalInfixExpression(op string, left, right object.Object) object.Object {
+// evalLogicalExpression only evaluates the right side when the left side
+// does not already decide the result, which is always a boolean.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Op == "||") {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalInfixExpression(op string, left, right object.Object, checked bool) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(op, left, right)
+		return evalIntegerInfixExpression(op, left, right, checked)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(op, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(op, left, right)
+	case op == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalStringRepetition(left, right)
+	case op == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringRepetition(right, left)
 	case op == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case op == "!=":
@@ -310,22 +665,71 @@ func evalInfixExpression(op string, left, right object.Object) object.Object {
 	}
 }
 
-func evalIntegerInfixExpression(op string, left, right object.Object) object.Object {
+func evalIntegerInfixExpression(op string, left, right object.Object, checked bool) object.Object {
 	leftVal := left.(*object.Integer).Val
 	rightVal := right.(*object.Integer).Val
 	switch op {
+	case "+", "-", "*", "/", "%", "**":
+		if (op == "/" || op == "%") && rightVal == 0 {
+			return newError(divisionByZero, left.Inspect(), op, right.Inspect())
+		}
+		if op == "**" && rightVal < 0 {
+			return &object.Float{Val: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+		res, overflow := IntegerArithmetic(op, leftVal, rightVal)
+		if overflow && checked {
+			return newError(integerOverflow, left.Inspect(), op, right.Inspect())
+		}
+		return &object.Integer{Val: res}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(unknownInfixOp, left.Type(), op, right.Type())
+	}
+}
+
+// evalFloatInfixExpression handles a float with a float or an integer, the
+// integer is widened to a float.
+func evalFloatInfixExpression(op string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch op {
 	case "+":
-		return &object.Integer{Val: leftVal + rightVal}
+		return &object.Float{Val: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Val: leftVal - rightVal}
+		return &object.Float{Val: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Val: leftVal * rightVal}
+		return &object.Float{Val: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Val: leftVal / rightVal}
+		if rightVal == 0 {
+			return newError(divisionByZero, left.Inspect(), op, right.Inspect())
+		}
+		return &object.Float{Val: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError(divisionByZero, left.Inspect(), op, right.Inspect())
+		}
+		return &object.Float{Val: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Val: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -335,21 +739,107 @@ func evalIntegerInfixExpression(op string, left, right object.Object) object.Obj
 	}
 }
 
-func evalStringInfixExpression(op string, left, right object.Object) object.Object {
-	if op != "+" {
-		return newError("Unknown operator: %s %s %s", left.Type(), op, right.Type())
+// IntegerArithmetic applies one of + - * / % ** to two integers and
+// reports whether the result wrapped around. The divisor of / and % must
+// not be zero and the exponent of ** must not be negative. The VM shares it
+// so both engines agree on every edge case.
+func IntegerArithmetic(op string, a, b int64) (int64, bool) {
+	switch op {
+	case "+":
+		res := a + b
+		return res, (a >= 0) == (b >= 0) && (res >= 0) != (a >= 0)
+	case "-":
+		res := a - b
+		return res, (a >= 0) != (b >= 0) && (res >= 0) != (a >= 0)
+	case "*":
+		return multiply(a, b)
+	case "/":
+		return a / b, a == math.MinInt64 && b == -1
+	case "%":
+		return a % b, false
+	case "**":
+		res, overflow := int64(1), false
+		for b > 0 {
+			var o bool
+			if b & 1 == 1 {
+				res, o = multiply(res, a)
+				overflow = overflow || o
+			}
+			b >>= 1
+			if b > 0 {
+				a, o = multiply(a, a)
+				overflow = overflow || o
+			}
+		}
+		return res, overflow
+	default:
+		return 0, false
+	}
+}
+
+func multiply(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, false
+	}
+	res := a * b
+	overflow := res / b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64)
+	return res, overflow
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Val)
 	}
+	return obj.(*object.Float).Val
+}
+
+// evalStringInfixExpression concatenates and compares strings, comparison
+// is by code point.
+func evalStringInfixExpression(op string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Val
 	rightVal := right.(*object.String).Val
-	return &object.String{Val: leftVal + rightVal}
+	switch op {
+	case "+":
+		return &object.String{Val: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(unknownInfixOp, left.Type(), op, right.Type())
+	}
 }
 
-func evalPrefixExpression(op string, right object.Object) object.Object {
+func evalStringRepetition(str, count object.Object) object.Object {
+	val := str.(*object.String).Val
+	n := count.(*object.Integer).Val
+	if n < 0 {
+		return newError("Negative repeat count: %d", n)
+	}
+	if len(val) > 0 && n > math.MaxInt32 / int64(len(val)) {
+		return newError("String repetition too large")
+	}
+	return &object.String{Val: strings.Repeat(val, int(n))}
+}
+
+func evalPrefixExpression(op string, right object.Object, checked bool) object.Object {
 	switch op {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return evalMinusPrefixOperatorExpression(right, checked)
 	default:
 		return newError("Unknown operator: %s%s", op, right.Type())
 	}
@@ -368,10 +858,17 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+func evalMinusPrefixOperatorExpression(right object.Object, checked bool) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		// MinInt64 is the only integer whose negation overflows.
+		if checked && right.Val == math.MinInt64 {
+			return newError(negationOverflow, right.Inspect())
+		}
+		return &object.Integer{Val: -right.Val}
+	case *object.Float:
+		return &object.Float{Val: -right.Val}
+	default:
 		return newError("Unknown operator: -%s", right.Type())
 	}
-	val := right.(*object.Integer).Val
-	return &object.Integer{Val: -val}
 }
@@ -1,9 +1,11 @@
 package evaluator 
 
 import(
+	"banana/ast"
 	"banana/lexer"
 	"banana/object"
 	"banana/parser"
+	"math"
 	"testing"
 )
 
@@ -154,8 +156,17 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("バナナ")`, 3},
+		{`len(bytes("バナナ"))`, 9},
+		{`bytes("é")[1]`, 169},
 		{`len(1)`, "Arg to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "Wrong number of args, got=2, expected=1"},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int("42")`, 42},
+		{`abs(-4)`, 4},
+		{`int("4.2")`, `Could not convert "4.2" to INTEGER`},
+		{`sqrt("x")`, "Arg to `sqrt` must be INTEGER or FLOAT, got STRING"},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -198,6 +209,47 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	} {
+		{`"abc"[0]`, "a"},
+		{`"naïve"[2]`, "ï"},
+		{`"🍌!"[1]`, "!"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("Object is not String, got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Val != expected {
+			t.Errorf("String has wrong value, expected=%q, got=%q", expected, str.Val)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	input := "\"say \\\"hi\\\"\\n\" + `C:\\raw`"
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("Object is not String, got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Val != "say \"hi\"\nC:\\raw" {
+		t.Errorf("String has wrong value, got=%q", str.Val)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 	let newAdder = fn(x) {
@@ -298,6 +350,66 @@ func TestErrorHandling(t *testing.T) {
 			`"hello" - "world"`,
 			"Unknown operator: STRING - STRING",
 		},
+		{
+			"1 / 0",
+			"Division by zero: 1 / 0",
+		},
+		{
+			"10 % (5 - 5)",
+			"Division by zero: 10 % 0",
+		},
+		{
+			"2.5 / 0",
+			"Division by zero: 2.5 / 0",
+		},
+		{
+			`"ab" * -1`,
+			"Negative repeat count: -1",
+		},
+		{
+			`"ab" % "a"`,
+			"Unknown operator: STRING % STRING",
+		},
+		{
+			"fn(a, b) { a + b; }(1)",
+			"Wrong number of args, got=1, expected=2",
+		},
+		{
+			"let a = [1, 2]; a[2] = 3",
+			"Index out of bounds: 2, length 2",
+		},
+		{
+			"let a = [1, 2]; a[-1] = 3",
+			"Index out of bounds: -1, length 2",
+		},
+		{
+			`let a = [1, 2]; a["0"] = 3`,
+			"Array index must be INTEGER, got STRING",
+		},
+		{
+			"let d = {}; d[[1]] = 3",
+			"Unusable as dict key: ARRAY",
+		},
+		{
+			`let s = "abc"; s[0] = "x"`,
+			"Index assignment not supported: STRING",
+		},
+		{
+			"let d = {}; d[1] += 1",
+			"Type mismatch: NULL + INTEGER",
+		},
+		{
+			"insert([1], 2, 0)",
+			"Index out of bounds: 2, length 1",
+		},
+		{
+			"delete([], 0)",
+			"Index out of bounds: 0, length 0",
+		},
+		{
+			"append!({}, 1)",
+			"Arg to `append!` must be ARRAY, got DICT",
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -312,6 +424,87 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input string
+		expectedPos string
+	} {
+		{"5 + true;", "1:1"},
+		{"let x = 1;\nlet y = x + foobar;", "2:13"},
+		{"let f = fn(a) {\n  -a\n};\nf(true)", "2:3"},
+		{"len(1, 2)", "1:1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned, got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("Wrong error position for %q, expected=%s, got=%s", tt.input, tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input string
+		expectedMessage string
+	} {
+		{"9223372036854775807 + 1", "Integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "Integer overflow: -9223372036854775807 - 2"},
+		{"4294967296 * 4294967296", "Integer overflow: 4294967296 * 4294967296"},
+		{"let f = fn(x) { x * 3 }; f(4611686018427387904)", "Integer overflow: 4611686018427387904 * 3"},
+		{"3 ** 40", "Integer overflow: 3 ** 40"},
+		{"let x = -9223372036854775807 - 1; -x", "Integer overflow: -(-9223372036854775808)"},
+		{"abs(-9223372036854775807 - 1)", "Integer overflow: abs(-9223372036854775808)"},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.EnableCheckedArithmetic()
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Msg != tt.expectedMessage {
+			t.Errorf("Wrong error message, expected=%q, got=%q", tt.expectedMessage, errObj.Msg)
+		}
+	}
+
+	// Without checking, arithmetic wraps around.
+	testIntegerObject(t, testEval("9223372036854775807 + 1"), -9223372036854775808)
+	testIntegerObject(t, testEval("3 ** 39"), 4052555153018976267)
+	testIntegerObject(t, testEval("let x = -9223372036854775807 - 1; -x"), -9223372036854775808)
+}
+
+func TestIntegerArithmeticOverflow(t *testing.T) {
+	tests := []struct {
+		op string
+		a, b int64
+		expected int64
+		overflow bool
+	} {
+		{"+", math.MaxInt64, 0, math.MaxInt64, false},
+		{"+", math.MinInt64, -1, math.MaxInt64, true},
+		{"-", 0, math.MinInt64, math.MinInt64, true},
+		{"-", -1, math.MaxInt64, math.MinInt64, false},
+		{"*", -1, math.MinInt64, math.MinInt64, true},
+		{"*", math.MinInt64, 1, math.MinInt64, false},
+		{"/", math.MinInt64, -1, math.MinInt64, true},
+		{"**", -2, 63, math.MinInt64, false},
+		{"**", 2, 64, 0, true},
+	}
+	for _, tt := range tests {
+		res, overflow := IntegerArithmetic(tt.op, tt.a, tt.b)
+		if res != tt.expected || overflow != tt.overflow {
+			t.Errorf("%d %s %d: expected=(%d, %t), got=(%d, %t)", tt.a, tt.op, tt.b, tt.expected, tt.overflow, res, overflow)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input string
@@ -349,6 +542,50 @@ func TestIfElseExpression(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	} {
+		{"match (1) { 1 => 10, _ => 20 }", 10},
+		{"match (2) { 1 => 10, _ => 20 }", 20},
+		{"match (3) { 1 => 10, 2 => 20 }", nil},
+		{"match (-1) { 1 => 10, -1 => 20 }", 20},
+		{"match (2.0) { 2 => 10 }", 10},
+		{`match ("b") { "a" => 10, "b" => 20 }`, 20},
+		{`match ("1") { 1 => 10, true => 20, _ => 30 }`, 30},
+		{"match (1 < 2) { false => 10, true => 20 }", 20},
+		{"match (5) { n => n * 2 }", 10},
+		{"match ([1, 2]) { [a] => a, [a, b] => a + b, _ => 0 }", 3},
+		{"match ([1, [2, 3]]) { [1, [a, 4]] => a, [1, [a, b]] => a * b }", 6},
+		{"match ([]) { [] => 1 }", 1},
+		{"match ({}) { [] => 1, {} => 2 }", 2},
+		{`match ({"a": 1, "b": [2]}) { {"c": c} => c, {"b": [x], "a": y} => x + y }`, 3},
+		{`match ({"a": 1}) { {"a": 2} => 10, {"a": _} => 20 }`, 20},
+		{`match ({1: 5, true: 6}) { {1: a, true: b} => a * b }`, 30},
+		{"match ([1, 2]) { {} => 1, [a, b] if a > b => 2, [a, b] if a < b => 3 }", 3},
+		{"match (7) { n if n % 2 == 0 => 1, n => n }", 7},
+		{"let x = 1; match ([2]) { [x] => x }; x", 1},
+		{"let x = 1; match (5) { x if x > 10 => 0, _ => 0 }; x", 1},
+		{"let x = 1; match (5) { x => x = 7 }; x", 1},
+		{"const c = 1; match (2) { c => c += 1 }", 3},
+		{"let x = 1; match ([2, 3]) { [x, 4] => x }; x", 1},
+		{"let f = fn(v) { match (v) { [a, b] => a + b, _ => -1 } }; f([3, 4]) + f(1)", 6},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -361,6 +598,204 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	} {
+		{"while (false) { 10 }", nil},
+		{"while (true) { break; }", nil},
+		{"while (true) { break; 10 }; 20", 20},
+		{"let f = fn() { while (true) { return 10; } }; f()", 10},
+		{"let f = fn() { while (true) { while (true) { break; } return 10; } }; f()", 10},
+		{"let f = fn(x) { while (x) { if (x > 5) { return x; } break; } }; f(10)", 10},
+		{"let f = fn(x) { while (x) { if (x > 5) { return x; } break; } }; f(1)", nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	} {
+		{"for (x in [1, 2, 3]) { x }", nil},
+		{"for (x in []) { x }; 5", 5},
+		{"let f = fn(xs) { for (x in xs) { if (x > 1) { return x; } } }; f([1, 2, 3])", 2},
+		{"let f = fn(xs) { for (i, x in xs) { if (x > 1) { return i; } } }; f([1, 2, 3])", 1},
+		{`let f = fn(d) { for (k in d) { return k; } }; f({"b": 1, "a": 2})`, "a"},
+		{`let f = fn(d) { for (k, v in d) { return v; } }; f({"b": 1, "a": 2})`, 2},
+		{`let f = fn(d) { for (k, v in d) { return v; } }; f({3: 1, 1: 2, 2: 3})`, 2},
+		{`let f = fn(s) { for (c in s) { if (c != "a") { return c; } } }; f("aé")`, "é"},
+		{"let f = fn() { for (i in range(10, 0, -3)) { if (i < 5) { return i; } } }; f()", 4},
+		{"let f = fn() { for (i in range(3)) { if (i < 1) { continue; } return i; } }; f()", 1},
+		{"let f = fn() { for (i in range(5)) { for (j in range(i)) { break; } if (i > 2) { return i; } } }; f()", 3},
+		{"for (i in range(3)) { }; i", 2},
+		{"for (x in 5) { x }", "ERROR: Not iterable: INTEGER"},
+		{"range(0, 1, 0)", "ERROR: Range step cannot be zero"},
+		{`range("a")`, "ERROR: Arg to `range` must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if "ERROR: " + errObj.Msg != expected {
+					t.Errorf("Wrong error message, expected=%q, got=%q", expected, errObj.Msg)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Val != expected {
+				t.Errorf("Object is not %q, got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	} {
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 2", 3},
+		{"let a = 1; let b = 2; a = b = 3; a + b", 6},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4", 2},
+		{"let x = 5; x **= 2; x", 25},
+		{"let a = [3]; a[0] **= 3; a", []int{27}},
+		{`let s = "a"; s += "b"; s *= 2; s`, "abab"},
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", 5},
+		{"let x = 1; let f = fn() { let x = 2; x = 3 }; f(); x", 1},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n }; f()", 2},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; if (i == 3) { continue; } sum += i; }; sum", 12},
+		{"let n = 0; while (true) { n += 1; if (n >= 4) { break; } }; n", 4},
+		{"let sum = 0; for (k, v in {1: 10, 2: 20}) { sum += k * v }; sum", 50},
+		{"y = 1", "Assignment to undefined variable: y"},
+		{"y += 1", "Assignment to undefined variable: y"},
+		{"len = 1", "Cannot assign to builtin: len"},
+		{"let x = 1; x += true", "Type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Msg != expected {
+					t.Errorf("Wrong error message, expected=%q, got=%q", expected, errObj.Msg)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Val != expected {
+				t.Errorf("Object is not %q, got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+// The parser rejects const misuse it can see, names bound on earlier REPL
+// lines are only known to the environment.
+func TestConstBindings(t *testing.T) {
+	tests := []struct {
+		inputs []string
+		expected interface{}
+	} {
+		{[]string{"const x = 1;", "x + 1"}, 2},
+		{[]string{"const x = 1;", "x = 2"}, "Cannot assign to const x declared at 1:1"},
+		{[]string{"let y = 0; const x = 1;", "x -= 1"}, "Cannot assign to const x declared at 1:12"},
+		{[]string{"const x = 1;", "let x = 2;"}, "Cannot redeclare const x declared at 1:1"},
+		{[]string{"const x = 1;", "for (x in [1]) { x }"}, "Cannot redeclare const x declared at 1:1"},
+		{[]string{"const x = 1;", "let f = fn() { x = 3 };", "f()"}, "Cannot assign to const x declared at 1:1"},
+		{[]string{"const x = 1;", "let f = fn() { let x = 2; x = 3 };", "f()"}, 3},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		var evaluated object.Object
+		for _, input := range tt.inputs {
+			p := parser.New(lexer.New(input))
+			evaluated = Eval(p.ParseProgram(), env)
+		}
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("No error object returned, got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Msg != expected {
+				t.Errorf("Wrong error message, expected=%q, got=%q", expected, errObj.Msg)
+			}
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	} {
+		{"let a = [1, 2, 3]; a[1] = 5; a", "[1, 5, 3]"},
+		{"let a = [1, 2, 3]; a[0] += 10", "11"},
+		{"let a = [[1], [2]]; a[1][0] *= 7; a", "[[1], [14]]"},
+		{"let a = [1]; let b = a; b[0] = 2; a", "[2]"},
+		{`let d = {"a": 1}; d["b"] = 2; d["a"] -= 1; d`, "{a: 0, b: 2}"},
+		{"let d = {1: 1}; d[1.0] = 2; d", "{1: 2}"},
+		{"let i = 0; let a = [0, 0]; let next = fn() { i += 1; i - 1 }; a[next()] += 5; [a, i]", "[[5, 0], 1]"},
+		{"let a = []; for (i in range(4)) { append!(a, i * i) }; a", "[0, 1, 4, 9]"},
+		{"let a = [1, 2]; append!(a, 3) == a", "true"},
+		{"let a = [1, 3]; insert(a, 1, 2); insert(a, 3, 4); insert(a, 0, 0)", "[0, 1, 2, 3, 4]"},
+		{"let a = [1, 2, 3]; [delete(a, 1), a]", "[2, [1, 3]]"},
+		{`let d = {"a": 1, "b": 2}; [delete(d, "a"), delete(d, "x"), d]`, "[1, null, {b: 2}]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("Wrong result for %q, expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	// The parser rejects these, build the trees by hand the way a macro could.
+	tests := []struct {
+		stmt ast.Statement
+		expectedMessage string
+	} {
+		{&ast.BreakStatement{}, "Break outside of a loop"},
+		{&ast.ContinueStatement{}, "Continue outside of a loop"},
+	}
+	for _, tt := range tests {
+		program := &ast.Program{Statements: []ast.Statement{tt.stmt}}
+		evaluated := Eval(program, object.NewEnvironment())
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned, got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if err.Msg != tt.expectedMessage {
+			t.Errorf("Wrong error message, expected=%q, got=%q", tt.expectedMessage, err.Msg)
+		}
+	}
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("Object is not NULL, got=%T (%+v)", obj, obj)
@@ -419,6 +854,125 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		expected float64
+	} {
+		{"3.5", 3.5},
+		{"-.5", -0.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5},
+		{"float(3)", 3},
+		{"abs(-2.5)", 2.5},
+		{"floor(2.7)", 2},
+		{"ceil(2.2)", 3},
+		{"sqrt(16)", 4},
+		{`float("1e3")`, 1000},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input string
+		expected bool
+	} {
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"1 < 1.5", true},
+		{"2.5 > 3", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"{1: 10}[1.0] == 10", true},
+		{"{2.5: 10}[2.5] == 10", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input string
+		expected bool
+	} {
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"a\"", true},
+		{"1 < 2 && 2 < 3", true},
+		{"false && foobar", false},
+		{"true || foobar", true},
+		{"let x = 0; let f = fn() { 1 }; x == 0 || f()", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+	evaluated := testEval("true && foobar")
+	if _, ok := evaluated.(*object.Error); !ok {
+		t.Errorf("Right side was not evaluated, got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestComparisonAndArithmeticOperators(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	} {
+		{"7 % 3", 7 % 3},
+		{"-7 % 3", -7 % 3},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"2 ** -1", 0.5},
+		{"7.5 % 2", 1.5},
+		{"2.0 ** 3", 8.0},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"1.5 >= 1", true},
+		{`"apple" < "banana"`, true},
+		{`"b" > "abc"`, true},
+		{`"a" <= "a"`, true},
+		{`"z" >= "zz"`, false},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"ab" * 3`, "ababab"},
+		{`2 * "xy"`, "xyxy"},
+		{`"ab" * 0`, ""},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("Object is not String, got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Val != expected {
+				t.Errorf("String has wrong value, expected=%q, got=%q", expected, str.Val)
+			}
+		}
+	}
+}
+
 func TestEvalIntegerExpression(t *testing.T) {
 	tests := []struct {
 		input string
@@ -468,6 +1022,19 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	res, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("Object is not Float, got=%T (%+v)", obj, obj)
+		return false
+	}
+	if res.Val != expected {
+		t.Errorf("Object has wrong value, got=%g, expected=%g", res.Val, expected)
+		return false
+	}
+	return true
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
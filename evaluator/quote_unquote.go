@@ -53,6 +53,9 @@ func convertObjectToAstNode(obj object.Object) ast.Node {
 			Literal: fmt.Sprintf("%d", obj.Val),
 		}
 		return &ast.IntegerLiteral{Token: t, Val: obj.Val}
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect()}
+		return &ast.FloatLiteral{Token: t, Val: obj.Val}
 	case *object.Quote:
 		return obj.Node
 	default:
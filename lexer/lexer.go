@@ -1,36 +1,66 @@
 package lexer
 
-import "banana/token"
+import (
+	"banana/token"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input string
 	position int
 	readPosition int
-	currentChar byte
+	currentChar rune
+	line int
+	column int
+	emitComments bool
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// NewWithComments returns a lexer that emits comments as COMMENT tokens
+// instead of skipping them, for tools that need to preserve them.
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.emitComments = true
+	return l
+}
+
 func (l *Lexer) readChar() {
+	if l.currentChar == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+	width := 0
 	if l.readPosition >= len(l.input) {
 		l.currentChar = 0
 	} else {
-		l.currentChar = l.input[l.readPosition]
+		l.currentChar, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+}
+
+// pos is the position of currentChar. Columns count runes, offsets count
+// bytes.
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
 }
 
 
@@ -38,6 +68,16 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhiteSpace()
+	for l.currentChar == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		start := l.pos()
+		tok = l.readComment()
+		tok.Pos, tok.End = start, l.pos()
+		if l.emitComments || tok.Type == token.ILLEGAL {
+			return tok
+		}
+		l.skipWhiteSpace()
+	}
+	start := l.pos()
 	
 	switch l.currentChar {
 	case '=':
@@ -46,17 +86,55 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(currentChar) + string(l.currentChar)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.currentChar)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.currentChar)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.PLUS_ASSIGN, Literal: "+="}
+		} else {
+			tok = newToken(token.PLUS, l.currentChar)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.currentChar)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.MINUS_ASSIGN, Literal: "-="}
+		} else {
+			tok = newToken(token.MINUS, l.currentChar)
+		}
 	case '*':
-		tok = newToken(token.MULT, l.currentChar)
+		if l.peekChar() == '*' {
+			l.readChar()
+			if l.peekChar() == '=' {
+				l.readChar()
+				tok = token.Token{Type: token.POW_ASSIGN, Literal: "**="}
+			} else {
+				tok = token.Token{Type: token.POW, Literal: "**"}
+			}
+		} else if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.MULT_ASSIGN, Literal: "*="}
+		} else {
+			tok = newToken(token.MULT, l.currentChar)
+		}
+	case '%':
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.MOD_ASSIGN, Literal: "%="}
+		} else {
+			tok = newToken(token.MOD, l.currentChar)
+		}
 	case '/':
-		tok = newToken(token.DIV, l.currentChar)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.DIV_ASSIGN, Literal: "/="}
+		} else {
+			tok = newToken(token.DIV, l.currentChar)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			currentChar := l.currentChar
@@ -66,10 +144,34 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.currentChar)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok = token.Token{Type: token.AND, Literal: "&&"}
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: fmt.Sprintf("Unexpected character %q", l.currentChar)}
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok = token.Token{Type: token.OR, Literal: "||"}
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: fmt.Sprintf("Unexpected character %q", l.currentChar)}
+		}
 	case '<':
-		tok = newToken(token.LT, l.currentChar)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.LT_EQ, Literal: "<="}
+		} else {
+			tok = newToken(token.LT, l.currentChar)
+		}
 	case '>':
-		tok = newToken(token.GT, l.currentChar)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.GT_EQ, Literal: ">="}
+		} else {
+			tok = newToken(token.GT, l.currentChar)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.currentChar)
 	case '(':
@@ -89,66 +191,209 @@ func (l *Lexer) NextToken() token.Token {
 	case ':':
 		tok = newToken(token.COLON, l.currentChar)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readString()
+	case '`':
+		tok = l.readRawString()
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
+		tok.Pos, tok.End = start, start
+		return tok
 	default:
 		if isLetter(l.currentChar) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookUpId(tok.Literal)
+			tok.Pos, tok.End = start, l.pos()
 			return tok
-		} else if isDigit(l.currentChar) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+		} else if isDigit(l.currentChar) || l.currentChar == '.' && isDigit(l.peekChar()) {
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos, tok.End = start, l.pos()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.currentChar)
+			tok = token.Token{Type: token.ILLEGAL, Literal: fmt.Sprintf("Unexpected character %q", l.currentChar)}
 		}
 	}
 
 	l.readChar()
+	tok.Pos, tok.End = start, l.pos()
 	return tok
 }
 
-func newToken(tokenType token.TokenType, currentChar byte) token.Token {
+func newToken(tokenType token.TokenType, currentChar rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(currentChar)}
 }
 
-func (l *Lexer) readString() string {
+// readString reads a double quoted string, which must close on the line it
+// starts on, and decodes its escape sequences. currentChar is left on the
+// closing quote. A bad escape does not stop the scan so lexing resumes after
+// the string.
+func (l *Lexer) readString() token.Token {
+	var out strings.Builder
+	problem := ""
+	for {
+		l.readChar()
+		switch l.currentChar {
+		case '"':
+			if problem != "" {
+				return token.Token{Type: token.ILLEGAL, Literal: problem}
+			}
+			return token.Token{Type: token.STRING, Literal: out.String()}
+		case '\n', 0:
+			return token.Token{Type: token.ILLEGAL, Literal: "Unterminated string literal"}
+		case '\\':
+			l.readChar()
+			if l.currentChar == '\n' || l.currentChar == 0 {
+				return token.Token{Type: token.ILLEGAL, Literal: "Unterminated string literal"}
+			}
+			err := l.readEscape(&out)
+			if err != "" && problem == "" {
+				problem = err
+			}
+		default:
+			out.WriteRune(l.currentChar)
+		}
+	}
+}
+
+var escapes = map[rune]rune {
+	'n': '\n',
+	't': '\t',
+	'r': '\r',
+	'0': 0,
+	'\\': '\\',
+	'"': '"',
+}
+
+// readEscape decodes the escape sequence whose first character after the
+// backslash is currentChar, leaving currentChar on its last character. It
+// returns a description of the problem for an invalid sequence.
+func (l *Lexer) readEscape(out *strings.Builder) string {
+	if c, ok := escapes[l.currentChar]; ok {
+		out.WriteRune(c)
+		return ""
+	}
+	if l.currentChar != 'u' {
+		return fmt.Sprintf("Invalid escape sequence \\%c", l.currentChar)
+	}
+	if l.peekChar() != '{' {
+		return "Invalid unicode escape, expected \\u{...}"
+	}
+	l.readChar()
+	position := l.position + 1
+	for l.peekChar() != '}' && l.peekChar() != '"' && l.peekChar() != '\n' && l.peekChar() != 0 {
+		l.readChar()
+	}
+	if l.peekChar() != '}' {
+		return "Invalid unicode escape, expected \\u{...}"
+	}
+	l.readChar()
+	digits := l.input[position: l.position]
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+		return fmt.Sprintf("Invalid unicode escape \\u{%s}", digits)
+	}
+	out.WriteRune(rune(code))
+	return ""
+}
+
+// readRawString reads a backtick string. It may span lines and has no
+// escapes.
+func (l *Lexer) readRawString() token.Token {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.currentChar == '"' || l.currentChar == 0 {
-			break
+		if l.currentChar == '`' {
+			return token.Token{Type: token.STRING, Literal: l.input[position: l.position]}
+		}
+		if l.currentChar == 0 {
+			return token.Token{Type: token.ILLEGAL, Literal: "Unterminated raw string literal"}
 		}
 	}
-	return l.input[position: l.position]
 }
 
+// readComment reads a // comment up to the end of the line or a /* */
+// comment, which may nest, and leaves currentChar just past it.
+func (l *Lexer) readComment() token.Token {
+	position := l.position
+	if l.peekChar() == '/' {
+		for l.currentChar != '\n' && l.currentChar != 0 {
+			l.readChar()
+		}
+		return token.Token{Type: token.COMMENT, Literal: l.input[position: l.position]}
+	}
+	l.readChar()
+	l.readChar()
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.currentChar == 0:
+			return token.Token{Type: token.ILLEGAL, Literal: "Unterminated block comment"}
+		case l.currentChar == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.currentChar == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
+		l.readChar()
+	}
+	return token.Token{Type: token.COMMENT, Literal: l.input[position: l.position]}
+}
+
+// readIdentifier also takes a single trailing !, by convention it marks a
+// function that modifies its argument like append!. a!=b is still a != b.
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 	for isLetter(l.currentChar) {
 		l.readChar()
 	}
+	if l.currentChar == '!' && l.peekChar() != '=' {
+		l.readChar()
+	}
 	return l.input[position: l.position]
 }
 
-func isLetter(currentChar byte) bool {
-	return 'a' <= currentChar && currentChar <= 'z' || 'A' <= currentChar && currentChar <= 'Z' || currentChar == '_'
+func isLetter(currentChar rune) bool {
+	return unicode.IsLetter(currentChar) || currentChar == '_'
 }
 
-func isDigit(currentChar byte) bool {
+// isDigit only accepts ASCII digits, other decimal digits are not numbers.
+func isDigit(currentChar rune) bool {
 	return '0' <= currentChar && currentChar <= '9'
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a float such as 3.14, .5 or 1e-9. A dot
+// or an exponent marker only belongs to the number when digits follow it.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+	l.readDigits()
+	if l.currentChar == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	if l.currentChar == 'e' || l.currentChar == 'E' {
+		next := l.peekChar()
+		if (next == '+' || next == '-') && l.readPosition + 1 < len(l.input) {
+			next = rune(l.input[l.readPosition + 1])
+		}
+		if isDigit(next) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.currentChar == '+' || l.currentChar == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+	return l.input[position: l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.currentChar) {
 		l.readChar()
 	}
-	return l.input[position: l.position]
 }
 
 func (l *Lexer) skipWhiteSpace() {
@@ -68,4 +68,272 @@ func TestNextToken(t *testing.T) {
 				i, tt.expectedLiteral, tok.Literal)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  \"hi\" != x"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos token.Position
+		expectedEnd token.Position
+	} {
+		{"let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"x", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{"=", token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"10", token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{";", token.Position{Offset: 10, Line: 1, Column: 11}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{"hi", token.Position{Offset: 14, Line: 2, Column: 3}, token.Position{Offset: 18, Line: 2, Column: 7}},
+		{"!=", token.Position{Offset: 19, Line: 2, Column: 8}, token.Position{Offset: 21, Line: 2, Column: 10}},
+		{"x", token.Position{Offset: 22, Line: 2, Column: 11}, token.Position{Offset: 23, Line: 2, Column: 12}},
+		{"", token.Position{Offset: 23, Line: 2, Column: 12}, token.Position{Offset: 23, Line: 2, Column: 12}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos || tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - span wrong for %q, expected=%+v-%+v, got=%+v-%+v", i, tok.Literal, tt.expectedPos, tt.expectedEnd, tok.Pos, tok.End)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	input := "a && b || c & d"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLiteral string
+	} {
+		{token.ID, "a"},
+		{token.AND, "&&"},
+		{token.ID, "b"},
+		{token.OR, "||"},
+		{token.ID, "c"},
+		{token.ILLEGAL, "Unexpected character '&'"},
+		{token.ID, "d"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token, expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComparisonAndArithmeticOperators(t *testing.T) {
+	input := "a <= b >= c % d ** e * f < g > h"
+
+	expected := []token.TokenType{
+		token.ID, token.LT_EQ, token.ID, token.GT_EQ, token.ID, token.MOD, token.ID,
+		token.POW, token.ID, token.MULT, token.ID, token.LT, token.ID, token.GT, token.ID, token.EOF,
+	}
+
+	l := New(input)
+	for i, expectedType := range expected {
+		tok := l.NextToken()
+		if tok.Type != expectedType {
+			t.Fatalf("tests[%d] - wrong token type, expected=%s, got=%s (%q)", i, expectedType, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := "a = b += c -= d *= e /= f %= g ** h /* x */ i **= j"
+
+	expected := []token.TokenType{
+		token.ID, token.ASSIGN, token.ID, token.PLUS_ASSIGN, token.ID, token.MINUS_ASSIGN, token.ID,
+		token.MULT_ASSIGN, token.ID, token.DIV_ASSIGN, token.ID, token.MOD_ASSIGN, token.ID,
+		token.POW, token.ID, token.ID, token.POW_ASSIGN, token.ID, token.EOF,
+	}
+
+	l := New(input)
+	for i, expectedType := range expected {
+		tok := l.NextToken()
+		if tok.Type != expectedType {
+			t.Fatalf("tests[%d] - wrong token type, expected=%s, got=%s (%q)", i, expectedType, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	input := "match (x) { _ => a == b, y if y >= 1 => c = d }"
+
+	expected := []token.TokenType{
+		token.MATCH, token.LPAREN, token.ID, token.RPAREN, token.LBRACE,
+		token.ID, token.ARROW, token.ID, token.EQ, token.ID, token.COMMA,
+		token.ID, token.IF, token.ID, token.GT_EQ, token.INT, token.ARROW, token.ID, token.ASSIGN, token.ID,
+		token.RBRACE, token.EOF,
+	}
+
+	l := New(input)
+	for i, expectedType := range expected {
+		tok := l.NextToken()
+		if tok.Type != expectedType {
+			t.Fatalf("tests[%d] - wrong token type, expected=%s, got=%s (%q)", i, expectedType, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestMutatingIdentifiers(t *testing.T) {
+	input := "append!(a) a!=b !c x!"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLiteral string
+	} {
+		{token.ID, "append!"},
+		{token.LPAREN, "("},
+		{token.ID, "a"},
+		{token.RPAREN, ")"},
+		{token.ID, "a"},
+		{token.NOT_EQ, "!="},
+		{token.ID, "b"},
+		{token.BANG, "!"},
+		{token.ID, "c"},
+		{token.ID, "x!"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token, expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 1; // trailing
+/* block /* nested */ still comment */ x / 2
+/* unterminated`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLiteral string
+	} {
+		{token.COMMENT, "// leading"},
+		{token.LET, "let"},
+		{token.ID, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block /* nested */ still comment */"},
+		{token.ID, "x"},
+		{token.DIV, "/"},
+		{token.INT, "2"},
+		{token.ILLEGAL, "Unterminated block comment"},
+		{token.EOF, ""},
+	}
+
+	withComments := NewWithComments(input)
+	skipped := New(input)
+	for i, tt := range tests {
+		tok := withComments.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token, expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tt.expectedType == token.COMMENT {
+			continue
+		}
+		tok = skipped.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token without comments, expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "42 3.14 .5 1e-9 2E+3 7e 1.x"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLiteral string
+	} {
+		{token.INT, "42"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, ".5"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2E+3"},
+		{token.INT, "7"},
+		{token.ID, "e"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "Unexpected character '.'"},
+		{token.ID, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token, expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := "\"a\\\"b\" \"tab\\there\\n\" \"\\u{1F34C}\\\\\" `raw\\n\nline` \"bad\\q\" 1 \"\\u{110000}\" \"open\n2 `never"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLiteral string
+	} {
+		{token.STRING, "a\"b"},
+		{token.STRING, "tab\there\n"},
+		{token.STRING, "\U0001F34C\\"},
+		{token.STRING, "raw\\n\nline"},
+		{token.ILLEGAL, "Invalid escape sequence \\q"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "Invalid unicode escape \\u{110000}"},
+		{token.ILLEGAL, "Unterminated string literal"},
+		{token.INT, "2"},
+		{token.ILLEGAL, "Unterminated raw string literal"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token, expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let café = \"バナナ🍌\"; naïve"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLiteral string
+		expectedColumn int
+	} {
+		{token.LET, "let", 1},
+		{token.ID, "café", 5},
+		{token.ASSIGN, "=", 10},
+		{token.STRING, "バナナ🍌", 12},
+		{token.SEMICOLON, ";", 18},
+		{token.ID, "naïve", 20},
+		{token.EOF, "", 25},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token, expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong column for %q, expected=%d, got=%d", i, tok.Literal, tt.expectedColumn, tok.Pos.Column)
+		}
+	}
+}
@@ -1,17 +1,130 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
+	"banana/compiler"
+	"banana/evaluator"
+	"banana/lexer"
+	"banana/object"
+	"banana/parser"
 	"banana/repl"
+	"banana/vm"
 )
 
+var engine = flag.String("engine", "eval", "use 'vm' or 'eval'")
+var optimize = flag.Bool("optimize", true, "fold constants and clean up jumps when compiling")
+var checked = flag.Bool("checked", false, "report integer overflow as an error in run")
+
 func main() {
+	flag.Parse()
+	commands := map[string]func(string) error{
+		"build": build,
+		"disasm": disasm,
+		"run": run,
+	}
+	if cmd, ok := commands[flag.Arg(0)]; ok {
+		if flag.NArg() != 2 {
+			fmt.Fprintf(os.Stderr, "usage: banana %s <file>\n", flag.Arg(0))
+			os.Exit(2)
+		}
+		err := cmd(flag.Arg(1))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! Welcome to the Banana programming language!\n", user.Username)
-	repl.Start(os.Stdin, os.Stdout)
-}
\ No newline at end of file
+	if *engine == "vm" {
+		repl.StartVM(os.Stdin, os.Stdout)
+	} else {
+		repl.Start(os.Stdin, os.Stdout)
+	}
+}
+
+// disasm compiles the script at path and prints the emitted bytecode.
+func disasm(path string) error {
+	byteCode, err := loadFile(path)
+	if err != nil {
+		return err
+	}
+	fmt.Print(byteCode.Disassemble())
+	return nil
+}
+
+// build compiles the script at path to a .bnc file next to it.
+func build(path string) error {
+	byteCode, err := compileFile(path)
+	if err != nil {
+		return err
+	}
+	out := strings.TrimSuffix(path, filepath.Ext(path)) + ".bnc"
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return byteCode.Encode(f)
+}
+
+// run executes a .bnc file or a script on the VM.
+func run(path string) error {
+	byteCode, err := loadFile(path)
+	if err != nil {
+		return err
+	}
+	machine := vm.New(byteCode)
+	if *checked {
+		machine.EnableCheckedArithmetic()
+	}
+	return machine.Run()
+}
+
+func loadFile(path string) (*compiler.ByteCode, error) {
+	if filepath.Ext(path) != ".bnc" {
+		return compileFile(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return compiler.Decode(f)
+}
+
+func compileFile(path string) (*compiler.ByteCode, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		msgs := []string{}
+		for _, err := range p.Errors() {
+			msgs = append(msgs, err.Error())
+		}
+		return nil, fmt.Errorf("Parser errors:\n\t%s", strings.Join(msgs, "\n\t"))
+	}
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded := evaluator.ExpandMacros(program, macroEnv)
+	comp := compiler.New()
+	if !*optimize {
+		comp.DisableOptimizations()
+	}
+	err = comp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("Compilation failed: %s", err)
+	}
+	return comp.ByteCode(), nil
+}
@@ -1,20 +1,40 @@
 package object
 
+import(
+	"fmt"
+	"banana/token"
+)
+
 type Environment struct {
 	store map[string]Object
+	consts map[string]token.Position // Where the const bindings in store were declared
 	outer *Environment
+	checked bool
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s}
+	c := make(map[string]token.Position)
+	return &Environment{store: s, consts: c}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.checked = outer.checked
 	return env
-} 
+}
+
+// EnableCheckedArithmetic makes integer arithmetic evaluated in this
+// environment, and in environments enclosed by it afterwards, report
+// overflow as an error instead of wrapping around.
+func (e *Environment) EnableCheckedArithmetic() {
+	e.checked = true
+}
+
+func (e *Environment) CheckedArithmetic() bool {
+	return e.checked
+}
 
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
@@ -27,4 +47,33 @@ func (e *Environment) Get(name string) (Object, bool) {
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
+}
+
+// Declare binds name in this environment for a let or const statement. A
+// const can't be declared again in the same environment.
+func (e *Environment) Declare(name string, val Object, isConst bool, pos token.Position) *Error {
+	if declared, ok := e.consts[name]; ok {
+		return &Error{Msg: fmt.Sprintf("Cannot redeclare const %s declared at %s", name, declared)}
+	}
+	e.store[name] = val
+	if isConst {
+		e.consts[name] = pos
+	}
+	return nil
+}
+
+// Assign updates an existing binding in the innermost environment that
+// defines name, ok is false if none of them does. A const binding is left
+// alone and reported as err.
+func (e *Environment) Assign(name string, val Object) (ok bool, err *Error) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			if declared, ok := env.consts[name]; ok {
+				return true, &Error{Msg: fmt.Sprintf("Cannot assign to const %s declared at %s", name, declared)}
+			}
+			env.store[name] = val
+			return true, nil
+		}
+	}
+	return false, nil
 }
\ No newline at end of file
@@ -0,0 +1,136 @@
+package object
+
+import(
+	"sort"
+	"unicode/utf8"
+)
+
+// Iterator steps through the elements of an array, dict, string or range.
+type Iterator struct {
+	next func() (Object, Object, bool)
+	keyed bool // A single loop variable is bound to the key, not the value
+}
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string { return "iterator" }
+
+// Next returns the next key and value, ok is false once the iterator is
+// exhausted. The key of an array, string or range element is its position.
+func (it *Iterator) Next() (key, val Object, ok bool) {
+	return it.next()
+}
+
+// NextElement returns what a loop with a single variable binds: the key of
+// a dict entry and the value of anything else.
+func (it *Iterator) NextElement() (Object, bool) {
+	key, val, ok := it.next()
+	if it.keyed {
+		return key, ok
+	}
+	return val, ok
+}
+
+// Iterate returns an iterator over obj, ok is false if obj is not iterable.
+func Iterate(obj Object) (*Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		i := 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if i >= len(obj.Elements) {
+				return nil, nil, false
+			}
+			i++
+			return &Integer{Val: int64(i - 1)}, obj.Elements[i - 1], true
+		}}, true
+	case *Dict:
+		pairs := obj.SortedPairs()
+		i := 0
+		return &Iterator{keyed: true, next: func() (Object, Object, bool) {
+			if i >= len(pairs) {
+				return nil, nil, false
+			}
+			i++
+			return pairs[i - 1].Key, pairs[i - 1].Val, true
+		}}, true
+	case *String:
+		offset, i := 0, 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if offset >= len(obj.Val) {
+				return nil, nil, false
+			}
+			_, width := utf8.DecodeRuneInString(obj.Val[offset:])
+			char := &String{Val: obj.Val[offset:offset + width]}
+			offset += width
+			i++
+			return &Integer{Val: int64(i - 1)}, char, true
+		}}, true
+	case *Range:
+		val, i, done := obj.Start, 0, false
+		return &Iterator{next: func() (Object, Object, bool) {
+			if done || obj.Step > 0 && val >= obj.End || obj.Step < 0 && val <= obj.End {
+				return nil, nil, false
+			}
+			current := val
+			val += obj.Step
+			// Stop instead of wrapping around past the largest integer.
+			done = obj.Step > 0 && val < current || obj.Step < 0 && val > current
+			i++
+			return &Integer{Val: int64(i - 1)}, &Integer{Val: current}, true
+		}}, true
+	default:
+		return nil, false
+	}
+}
+
+// SortedPairs returns the pairs of d ordered by key: booleans first, then
+// numbers and then strings, each in ascending order. Go maps have no stable
+// order, this is what iteration and printing use instead.
+func (d *Dict) SortedPairs() []DictPair {
+	pairs := make([]DictPair, 0, len(d.Pairs))
+	for _, pair := range d.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return keyLess(pairs[i].Key, pairs[j].Key)
+	})
+	return pairs
+}
+
+func keyLess(a, b Object) bool {
+	rankA, rankB := keyRank(a), keyRank(b)
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	switch a := a.(type) {
+	case *Boolean:
+		return !a.Val && b.(*Boolean).Val
+	case *String:
+		return a.Val < b.(*String).Val
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Val < b.Val
+		}
+	}
+	return keyNumber(a) < keyNumber(b)
+}
+
+func keyRank(obj Object) int {
+	switch obj.(type) {
+	case *Boolean:
+		return 0
+	case *Integer, *Float:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func keyNumber(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Val)
+	case *Float:
+		return obj.Val
+	default:
+		return 0
+	}
+}
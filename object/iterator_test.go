@@ -0,0 +1,67 @@
+package object
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestIterate(t *testing.T) {
+	dict := &Dict{Pairs: map[DictKey]DictPair{}}
+	for _, key := range []Object{&String{Val: "b"}, &Integer{Val: 2}, &String{Val: "a"}, &Float{Val: 1.5}, &Boolean{Val: true}} {
+		dict.Pairs[key.(Hashable).DictKey()] = DictPair{Key: key, Val: &Integer{Val: 0}}
+	}
+	tests := []struct {
+		input Object
+		expectedKeys string
+		expectedElements string
+	} {
+		{
+			&Array{Elements: []Object{&Integer{Val: 7}, &String{Val: "x"}}},
+			"0 1",
+			"7 x",
+		},
+		{dict, "true 1.5 2 a b", "true 1.5 2 a b"},
+		{&String{Val: "aé☃"}, "0 1 2", "a é ☃"},
+		{&Range{Start: 0, End: 3, Step: 1}, "0 1 2", "0 1 2"},
+		{&Range{Start: 5, End: 0, Step: -2}, "0 1 2", "5 3 1"},
+		{&Range{Start: 0, End: -3, Step: 1}, "", ""},
+		{&Range{Start: math.MaxInt64 - 1, End: math.MaxInt64, Step: 5}, "0", "9223372036854775806"},
+	}
+	for _, tt := range tests {
+		it, ok := Iterate(tt.input)
+		if !ok {
+			t.Fatalf("%s is not iterable", tt.input.Type())
+		}
+		keys := []string{}
+		for key, _, ok := it.Next(); ok; key, _, ok = it.Next() {
+			keys = append(keys, key.Inspect())
+		}
+		if got := strings.Join(keys, " "); got != tt.expectedKeys {
+			t.Errorf("Wrong keys for %s, expected=%q, got=%q", tt.input.Inspect(), tt.expectedKeys, got)
+		}
+		it, _ = Iterate(tt.input)
+		elements := []string{}
+		for el, ok := it.NextElement(); ok; el, ok = it.NextElement() {
+			elements = append(elements, el.Inspect())
+		}
+		if got := strings.Join(elements, " "); got != tt.expectedElements {
+			t.Errorf("Wrong elements for %s, expected=%q, got=%q", tt.input.Inspect(), tt.expectedElements, got)
+		}
+	}
+	if _, ok := Iterate(&Integer{Val: 1}); ok {
+		t.Errorf("INTEGER should not be iterable")
+	}
+}
+
+func TestDictInspectIsSorted(t *testing.T) {
+	dict := &Dict{Pairs: map[DictKey]DictPair{}}
+	for i := 10; i > 0; i-- {
+		key := &Integer{Val: int64(i)}
+		dict.Pairs[key.DictKey()] = DictPair{Key: key, Val: &Boolean{Val: i % 2 == 0}}
+	}
+	expected := "{1: false, 2: true, 3: false, 4: true, 5: false, 6: true, 7: false, 8: true, 9: false, 10: true}"
+	if got := dict.Inspect(); got != expected {
+		t.Errorf("Wrong Inspect, expected=%q, got=%q", expected, got)
+	}
+}
@@ -4,8 +4,12 @@ import(
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"strconv"
 	"strings"
 	"banana/ast"
+	"banana/code"
+	"banana/token"
 )
 
 type ObjectType string
@@ -13,14 +17,22 @@ type ObjectType string
 const (
 	ARRAY_OBJ = "ARRAY"
 	BOOLEAN_OBJ = "BOOLEAN"
+	BREAK_OBJ = "BREAK"
 	BUILTIN_OBJ = "BUILTIN"
+	CELL_OBJ = "CELL"
+	CLOSURE_OBJ = "CLOSURE"
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CONTINUE_OBJ = "CONTINUE"
 	DICT_OBJ = "DICT"
 	ERROR_OBJ = "ERROR"
+	FLOAT_OBJ = "FLOAT"
 	FUNCTION_OBJ = "FUNCTION"
 	INTEGER_OBJ = "INTEGER"
+	ITERATOR_OBJ = "ITERATOR"
 	MACRO_OBJ = "MACRO"
 	NULL_OBJ = "NULL"
 	QUOTE_OBJ = "QUOTE"
+	RANGE_OBJ = "RANGE"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	STRING_OBJ = "STRING"
 )
@@ -66,6 +78,12 @@ func (b *Boolean) DictKey() DictKey {
 	return DictKey{Type: b.Type(), Val: val}
 }
 
+// Break and Continue unwind the evaluation of a loop body the same way
+// ReturnValue unwinds a function body.
+type Break struct {}
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string { return "break" }
+
 type BuiltinFunction func(args ...Object) Object
 type Builtin struct {
 	Fn BuiltinFunction
@@ -73,6 +91,34 @@ type Builtin struct {
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string { return "builtin function" }
 
+// Cell holds a local variable of the VM once a closure captures it, so the
+// function and the closure keep seeing each other's assignments.
+type Cell struct {
+	Val Object
+}
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string { return c.Val.Inspect() }
+
+type Closure struct {
+	Fn *CompiledFunction
+	Free []Object
+}
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string { return fmt.Sprintf("Closure[%p]", c) }
+
+type CompiledFunction struct {
+	Instructions code.Instructions
+	Positions code.Positions // Source positions of Instructions, for errors
+	NumLocals int
+	NumParameters int
+}
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string { return fmt.Sprintf("CompiledFunction[%p]", cf) }
+
+type Continue struct {}
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string { return "continue" }
+
 type Dict struct {
 	Pairs map[DictKey]DictPair
 }
@@ -80,7 +126,7 @@ func (d *Dict) Type() ObjectType { return DICT_OBJ }
 func (d *Dict) Inspect() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for _, pair := range d.Pairs {
+	for _, pair := range d.SortedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Val.Inspect()))
 	}
 	out.WriteString("{")
@@ -102,9 +148,35 @@ type DictPair struct {
 
 type Error struct {
 	Msg string
+	Pos token.Position // Where in the source the error was raised, if known
 }
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string { return "ERROR: " + e.Msg }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("ERROR: %s: %s", e.Pos, e.Msg)
+	}
+	return "ERROR: " + e.Msg
+}
+
+type Float struct {
+	Val float64
+}
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Val, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+// DictKey hashes integral floats like the equal Integer so 1.0 and 1 name
+// the same entry, as they compare equal with ==.
+func (f *Float) DictKey() DictKey {
+	if f.Val == math.Trunc(f.Val) && f.Val >= math.MinInt64 && f.Val < math.MaxInt64 {
+		return DictKey{Type: INTEGER_OBJ, Val: uint64(int64(f.Val))}
+	}
+	return DictKey{Type: f.Type(), Val: math.Float64bits(f.Val)}
+}
 
 type Function struct {
 	Parameters []*ast.Identifier
@@ -167,6 +239,16 @@ type Quote struct {
 func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
 func (q *Quote) Inspect() string { return "QUOTE(" + q.Node.String() + ")" }
 
+// Range is the lazy sequence start, start + step, ... up to but not
+// including end. Its elements are only produced while iterating over it.
+type Range struct {
+	Start int64
+	End int64
+	Step int64
+}
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string { return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step) }
+
 type ReturnValue struct {
 	Val Object
 }
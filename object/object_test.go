@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringDictKey(t *testing.T) {
 	hello1 := &String{Val: "Hello World"}
@@ -13,4 +16,35 @@ func TestStringDictKey(t *testing.T) {
 	if hello1.DictKey() == diff1.DictKey() {
 		t.Errorf("Strings with different content have same dict keys.")
 	}
-}
\ No newline at end of file
+}
+func TestFloatDictKey(t *testing.T) {
+	if (&Float{Val: 2.5}).DictKey() != (&Float{Val: 2.5}).DictKey() {
+		t.Errorf("Floats with same value have different dict keys.")
+	}
+	if (&Float{Val: 2.5}).DictKey() == (&Float{Val: 3.5}).DictKey() {
+		t.Errorf("Floats with different values have same dict keys.")
+	}
+	if (&Float{Val: 1}).DictKey() != (&Integer{Val: 1}).DictKey() {
+		t.Errorf("Float 1.0 and Integer 1 have different dict keys.")
+	}
+	if (&Float{Val: math.Copysign(0, -1)}).DictKey() != (&Integer{Val: 0}).DictKey() {
+		t.Errorf("Float -0.0 and Integer 0 have different dict keys.")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input float64
+		expected string
+	} {
+		{3, "3.0"},
+		{3.14, "3.14"},
+		{1e-9, "1e-09"},
+		{math.Inf(1), "+Inf"},
+	}
+	for _, tt := range tests {
+		if got := (&Float{Val: tt.input}).Inspect(); got != tt.expected {
+			t.Errorf("Wrong Inspect for %g, expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
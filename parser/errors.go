@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"fmt"
+	"banana/token"
+)
+
+type ErrorKind string
+
+const (
+	UnexpectedToken ErrorKind = "UNEXPECTED_TOKEN"
+	NoPrefixParseFn ErrorKind = "NO_PREFIX_PARSE_FN"
+	InvalidInteger ErrorKind = "INVALID_INTEGER"
+	InvalidFloat ErrorKind = "INVALID_FLOAT"
+	IllegalToken ErrorKind = "ILLEGAL_TOKEN"
+	OutsideLoop ErrorKind = "OUTSIDE_LOOP"
+	ControlInExpression ErrorKind = "CONTROL_IN_EXPRESSION"
+	InvalidAssignment ErrorKind = "INVALID_ASSIGNMENT"
+	ConstAssignment ErrorKind = "CONST_ASSIGNMENT"
+	ConstRedeclaration ErrorKind = "CONST_REDECLARATION"
+	InvalidPattern ErrorKind = "INVALID_PATTERN"
+)
+
+type ParseError struct {
+	Kind ErrorKind
+	Expected token.TokenType // Only set for UnexpectedToken
+	Got token.Token
+	Pos token.Position
+	Declared token.Position // Only set for const errors, where the const is declared
+}
+
+func (e *ParseError) Error() string {
+	switch e.Kind {
+	case UnexpectedToken:
+		return fmt.Sprintf("%s: Expected next token to be %s, got %s instead", e.Pos, e.Expected, e.Got.Type)
+	case NoPrefixParseFn:
+		return fmt.Sprintf("%s: no prefix parse function %s found", e.Pos, e.Got.Type)
+	case InvalidInteger:
+		return fmt.Sprintf("%s: Could not parse %q as integer.", e.Pos, e.Got.Literal)
+	case InvalidFloat:
+		return fmt.Sprintf("%s: Could not parse %q as float.", e.Pos, e.Got.Literal)
+	case OutsideLoop:
+		return fmt.Sprintf("%s: %s outside of a loop", e.Pos, e.Got.Literal)
+	case ControlInExpression:
+		return fmt.Sprintf("%s: %s cannot be used inside an expression", e.Pos, e.Got.Literal)
+	case InvalidAssignment:
+		return fmt.Sprintf("%s: Invalid assignment target for %s", e.Pos, e.Got.Literal)
+	case ConstAssignment:
+		return fmt.Sprintf("%s: Cannot assign to const %s declared at %s", e.Pos, e.Got.Literal, e.Declared)
+	case ConstRedeclaration:
+		return fmt.Sprintf("%s: Cannot redeclare const %s declared at %s", e.Pos, e.Got.Literal, e.Declared)
+	case InvalidPattern:
+		return fmt.Sprintf("%s: Unexpected %s in pattern", e.Pos, e.Got.Type)
+	case IllegalToken:
+		// The lexer describes the problem in the literal of ILLEGAL tokens.
+		return fmt.Sprintf("%s: %s", e.Pos, e.Got.Literal)
+	default:
+		return fmt.Sprintf("%s: Unexpected %s", e.Pos, e.Got.Type)
+	}
+}
+
+// addError records err unless the parser is already recovering from an
+// earlier error in the same statement, those later errors are almost always
+// a consequence of the first one.
+func (p *Parser) addError(err *ParseError) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.errors = append(p.errors, err)
+}
+
+// synchronize skips the rest of a broken statement and leaves curToken on
+// the first token of the next one: after a ;, or at a let, const, return,
+// while or for. Braces opened while skipping are skipped up to the matching
+// }, a } closing the current block is left for it and a stray } at the top
+// level is skipped. start is the token the broken statement began with, it
+// is always skipped so the parser makes progress.
+func (p *Parser) synchronize(start token.Token) {
+	p.panicking = false
+	if p.curToken.Pos == start.Pos {
+		p.nextToken()
+	}
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.SEMICOLON:
+			if depth == 0 {
+				p.nextToken()
+				return
+			}
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			} else if p.blockDepth > 0 {
+				return
+			}
+		case token.LET, token.CONST, token.RETURN, token.WHILE, token.FOR:
+			if depth == 0 {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// skipSemicolon consumes the optional ; ending a statement. A broken
+// statement leaves it to synchronize, the parser may have stopped on a }
+// that the ; follows.
+func (p *Parser) skipSemicolon() {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+}
@@ -4,31 +4,47 @@ import (
 	"banana/ast"
 	"banana/lexer"
 	"banana/token"
-	"fmt"
 	"strconv"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN		// x = y
+	OR			// ||
+	AND			// &&
 	EQUALS		// ==
 	LESSGREATER // < or >
 	SUM			// +
 	PRODUCT		// *
 	PREFIX     	// -x or !x
+	POWER		// x ** y
 	CALL        // my_function(x)
 	INDEX		// my_array[index]
 )
 
 var precedences = map[token.TokenType]int {
+	token.ASSIGN: ASSIGN,
+	token.PLUS_ASSIGN: ASSIGN,
+	token.MINUS_ASSIGN: ASSIGN,
+	token.MULT_ASSIGN: ASSIGN,
+	token.DIV_ASSIGN: ASSIGN,
+	token.MOD_ASSIGN: ASSIGN,
+	token.POW_ASSIGN: ASSIGN,
+	token.OR: OR,
+	token.AND: AND,
 	token.EQ: EQUALS,
 	token.NOT_EQ: EQUALS,
 	token.LT: LESSGREATER,
 	token.GT: LESSGREATER,
+	token.LT_EQ: LESSGREATER,
+	token.GT_EQ: LESSGREATER,
 	token.PLUS: SUM,
 	token.MINUS: SUM,
 	token.MULT: PRODUCT,
 	token.DIV: PRODUCT,
+	token.MOD: PRODUCT,
+	token.POW: POWER,
 	token.LPAREN: CALL,
 	token.LBRACKET: INDEX,
 }
@@ -40,7 +56,12 @@ type (
 
 type Parser struct {
 	l *lexer.Lexer
-	errors []string
+	errors []*ParseError
+	panicking bool
+	loopDepth int // Number of loops around curToken within the current function
+	breakable bool // Whether a break or continue at curToken would be a statement of a loop body
+	blockDepth int // Number of blocks being parsed around curToken
+	scopes []map[string]declaration // Names bound in each enclosing function, innermost last
 
 	curToken token.Token
 	peekToken token.Token
@@ -52,7 +73,8 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l: l, 
-		errors: []string{},
+		errors: []*ParseError{},
+		scopes: []map[string]declaration{{}},
 	}
 	p.nextToken()
 	p.nextToken()
@@ -60,39 +82,54 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.ID, p.parseIdentifier)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.LBRACE, p.parseDictLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupExpression)
 	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.DIV_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MOD_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MULT_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.POW_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.DIV, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
+	p.registerInfix(token.MOD, p.parseInfixExpression)
 	p.registerInfix(token.MULT, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
+	p.registerInfix(token.POW, p.parseInfixExpression)
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("Expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(&ParseError{Kind: UnexpectedToken, Expected: t, Got: p.peekToken, Pos: p.peekToken.Pos})
 }
 
 func (p *Parser) nextToken() {
@@ -137,7 +174,12 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.EOF {
+		start := p.curToken
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize(start)
+			continue
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -149,15 +191,45 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.addError(&ParseError{Kind: OutsideLoop, Got: p.curToken, Pos: p.curToken.Pos})
+	} else if !p.breakable {
+		p.addError(&ParseError{Kind: ControlInExpression, Got: p.curToken, Pos: p.curToken.Pos})
+	}
+	p.skipSemicolon()
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.addError(&ParseError{Kind: OutsideLoop, Got: p.curToken, Pos: p.curToken.Pos})
+	} else if !p.breakable {
+		p.addError(&ParseError{Kind: ControlInExpression, Got: p.curToken, Pos: p.curToken.Pos})
+	}
+	p.skipSemicolon()
+	return stmt
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 	if !p.expectPeek(token.ID) {
@@ -169,9 +241,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	p.nextToken()
 	stmt.Val = p.parseExpression(LOWEST)
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	if fl, ok := stmt.Val.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Val
 	}
+	p.declare(stmt.Name, stmt.IsConst(), stmt.Pos())
+	p.skipSemicolon()
 	return stmt
 }
 
@@ -179,43 +253,187 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
 	stmt.ReturnVal = p.parseExpression(LOWEST)
-	if p.peekTokenIs(token.SEMICOLON) {
+	p.skipSemicolon()
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	p.skipSemicolon()
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.ID) {
+		return nil
+	}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Val: p.curToken.Literal}
+	if p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if !p.expectPeek(token.ID) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Val: p.curToken.Literal}
 	}
+	if stmt.Key != nil {
+		p.declare(stmt.Key, false, stmt.Key.Pos())
+	}
+	p.declare(stmt.Value, false, stmt.Value.Pos())
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	p.skipSemicolon()
 	return stmt
 }
 
+// parseLoopBody parses the body of a while or for loop, its statements may
+// break or continue the loop.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	breakable := p.breakable
+	p.breakable = true
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+	p.breakable = breakable
+	return body
+}
+
+// parseFunctionBody parses the body of a function or macro literal, loops
+// around the literal do not extend into it.
+func (p *Parser) parseFunctionBody(params []*ast.Identifier) *ast.BlockStatement {
+	loopDepth, breakable := p.loopDepth, p.breakable
+	p.loopDepth, p.breakable = 0, false
+	p.enterScope(params)
+	body := p.parseBlockStatement()
+	p.leaveScope()
+	p.loopDepth, p.breakable = loopDepth, breakable
+	return body
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		start := p.curToken
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize(start)
+			continue
+		}
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	if p.curTokenIs(token.EOF) {
+		p.addError(&ParseError{Kind: UnexpectedToken, Expected: token.RBRACE, Got: p.curToken, Pos: p.curToken.Pos})
+	}
+	block.EndPos = p.curToken.End
 	return block
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
-	stmt.Expression = p.parseExpression(LOWEST)
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	if p.curTokenIs(token.IF) && p.breakable {
+		stmt.Expression = p.parseIfStatement()
+	} else {
+		stmt.Expression = p.parseExpression(LOWEST)
 	}
+	p.skipSemicolon()
 	return stmt
 }
 
+// parseIfStatement parses an if expression in statement position of a loop
+// body, so its blocks may break or continue the loop. That is only allowed
+// while the if is not the left operand of a larger expression.
+func (p *Parser) parseIfStatement() ast.Expression {
+	expr := p.parseIfExpression()
+	if expr == nil {
+		return nil
+	}
+	left := p.parseInfixExpressions(expr, LOWEST)
+	if left == expr {
+		return expr
+	}
+	if tok, ok := loopControl(expr.(*ast.IfExpression)); ok {
+		p.addError(&ParseError{Kind: ControlInExpression, Got: tok, Pos: tok.Pos})
+		return nil
+	}
+	return left
+}
+
+// loopControl finds a break or continue in the blocks of expr, nested if
+// statements included, that would leave the loop around expr.
+func loopControl(expr *ast.IfExpression) (token.Token, bool) {
+	for _, block := range []*ast.BlockStatement{expr.Consequence, expr.Alternative} {
+		if block == nil {
+			continue
+		}
+		for _, stmt := range block.Statements {
+			switch stmt := stmt.(type) {
+			case *ast.BreakStatement:
+				return stmt.Token, true
+			case *ast.ContinueStatement:
+				return stmt.Token, true
+			case *ast.ExpressionStatement:
+				if nested, ok := stmt.Expression.(*ast.IfExpression); ok {
+					if tok, ok := loopControl(nested); ok {
+						return tok, true
+					}
+				}
+			}
+		}
+	}
+	return token.Token{}, false
+}
+
+// parseExpression parses an expression at curToken. A break or continue can
+// never be part of an expression, the blocks of ifs inside it do not see the
+// loop around them.
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.noPrefixParseFnError(p.curToken.Type)
+		p.noPrefixParseFnError()
 		return nil
 	}
-	leftExp := prefix()
+	breakable := p.breakable
+	p.breakable = false
+	defer func() { p.breakable = breakable }()
+	return p.parseInfixExpressions(prefix(), precedence)
+}
 
+// parseInfixExpressions applies the infix operators after leftExp that bind
+// tighter than precedence.
+func (p *Parser) parseInfixExpressions(leftExp ast.Expression, precedence int) ast.Expression {
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
@@ -228,9 +446,8 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function %s found", t)
-	p.errors = append(p.errors, msg)
+func (p *Parser) noPrefixParseFnError() {
+	p.addError(&ParseError{Kind: NoPrefixParseFn, Got: p.curToken, Pos: p.curToken.Pos})
 }
 
 // Parse prefix functions
@@ -252,6 +469,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.EndPos = p.curToken.End
 	return array
 }
 
@@ -274,6 +492,7 @@ func (p *Parser) parseDictLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	dict.EndPos = p.curToken.End
 	return dict
 }
 
@@ -300,6 +519,15 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Val: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	val, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(&ParseError{Kind: InvalidFloat, Got: p.curToken, Pos: p.curToken.Pos})
+		return nil
+	}
+	return &ast.FloatLiteral{Token: p.curToken, Val: val}
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 	if !p.expectPeek(token.LPAREN) {
@@ -309,7 +537,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseFunctionBody(lit.Parameters)
 	return lit
 }
 
@@ -363,6 +591,14 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expr.Consequence = p.parseBlockStatement()
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			expr.Alternative = p.parseElseIf()
+			if expr.Alternative == nil {
+				return nil
+			}
+			return expr
+		}
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -371,12 +607,31 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expr
 }
 
+// parseElseIf wraps the if expression after an else in a block of its own,
+// so an else if chain is just nested if expressions.
+func (p *Parser) parseElseIf() *ast.BlockStatement {
+	tok := p.curToken
+	nested := p.parseIfExpression()
+	if nested == nil {
+		return nil
+	}
+	return &ast.BlockStatement{
+		Token: tok,
+		Statements: []ast.Statement{&ast.ExpressionStatement{Token: tok, Expression: nested}},
+		EndPos: nested.End(),
+	}
+}
+
+func (p *Parser) parseIllegal() ast.Expression {
+	p.addError(&ParseError{Kind: IllegalToken, Got: p.curToken, Pos: p.curToken.Pos})
+	return nil
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	il := &ast.IntegerLiteral{Token: p.curToken}
 	val, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("Could not parse %q as integer.", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(&ParseError{Kind: InvalidInteger, Got: p.curToken, Pos: p.curToken.Pos})
 		return nil
 	}
 	il.Val = val
@@ -393,7 +648,7 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseFunctionBody(lit.Parameters)
 	return lit
 }
 
@@ -413,15 +668,41 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left: left,
 	}
 	precedence := p.curPrecedence()
+	// ** is right associative, 2 ** 3 ** 2 is 2 ** (3 ** 2).
+	if expr.Token.Type == token.POW {
+		precedence--
+	}
 	p.nextToken()
 	expr.Right = p.parseExpression(precedence)
 
 	return expr
 }
 
+// parseAssignExpression is right associative, a = b = 1 assigns 1 to both.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expr := &ast.AssignExpression{
+		Token: p.curToken,
+		Target: target,
+		Op: p.curToken.Literal,
+	}
+	switch target := target.(type) {
+	case *ast.Identifier:
+		p.checkAssignment(target)
+	case *ast.IndexExpression:
+	default:
+		p.addError(&ParseError{Kind: InvalidAssignment, Got: p.curToken, Pos: target.Pos()})
+		return nil
+	}
+	p.nextToken()
+	expr.Val = p.parseExpression(ASSIGN - 1)
+
+	return expr
+}
+
 func (p *Parser) parseCallExpression(fun ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Fun: fun}
 	exp.Args = p.parseExpressionList(token.RPAREN)
+	exp.EndPos = p.curToken.End
 	return exp
 } 
 
@@ -451,5 +732,6 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.EndPos = p.curToken.End
 	return exp
 }
\ No newline at end of file
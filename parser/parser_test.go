@@ -4,6 +4,7 @@ import (
 	"testing"
 	"banana/ast"
 	"banana/lexer"
+	"banana/token"
 	"fmt"
 )
 
@@ -288,6 +289,158 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { 0 }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf(incorrectStmtsLen, 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf(notExprStmt, program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Exression is not ast.IfExpression, got=%T", stmt.Expression)
+	}
+
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("exp.Alternative is not 1 statement, got=%+v", exp.Alternative)
+	}
+
+	nested, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Alternative.Statements[0] is not ast.ExpressionStatement, got=%T", exp.Alternative.Statements[0])
+	}
+
+	elseIf, ok := nested.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("nested.Expression is not ast.IfExpression, got=%T", nested.Expression)
+	}
+
+	if !testInfixExpression(t, elseIf.Condition, "x", ">", "y") {
+		return
+	}
+
+	if elseIf.Alternative == nil {
+		t.Errorf("elseIf.Alternative is nil")
+	}
+
+	if exp.End().Offset != len(input) {
+		t.Errorf("Wrong end offset, expected=%d, got=%d", len(input), exp.End().Offset)
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	} {
+		{"match (x) {}", "match(x) {}"},
+		{"match (x) { 1 => a, _ => b }", "match(x) {1 => a, _ => b}"},
+		{"match (x + 1) { -1 => a, 2.5 => b, \"s\" => c, true => d, }", "match((x + 1)) {(-1) => a, 2.5 => b, s => c, true => d}"},
+		{"match (x) { [a, [_, b]] => a + b, [] => 0 }", "match(x) {[a, [_, b]] => (a + b), [] => 0}"},
+		{"match (x) { {\"k\": [v], 1: _} => v, {} => 0 }", "match(x) {{k: [v], 1: _} => v, {} => 0}"},
+		{"match (x) { n if n > 1 && n < 5 => n = 0 }", "match(x) {n if ((n > 1) && (n < 5)) => (n = 0)}"},
+		{"match (x) { y => { \"a\": y } }", "match(x) {y => {a: y}}"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf(incorrectStmtsLen, 1, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf(notExprStmt, program.Statements[0])
+		}
+		if _, ok := stmt.Expression.(*ast.MatchExpression); !ok {
+			t.Fatalf("stmt.Expression is not ast.MatchExpression, got=%T", stmt.Expression)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("Wrong string, expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { if (x) { break; } continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf(incorrectStmtsLen, 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement, got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements, got=%d\n", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[1] is not ast.ContinueStatement, got=%T", stmt.Body.Statements[1])
+	}
+
+	if stmt.String() != "while(x < y) ifx break;continue;" {
+		t.Errorf("Wrong string, got=%q", stmt.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input string
+		expectedKey string
+		expectedValue string
+		expectedString string
+	} {
+		{"for (x in xs) { x }", "", "x", "for(x in xs) x"},
+		{"for (k, v in {1: 2}) { break; };", "k", "v", "for(k, v in {1: 2}) break;"},
+		{"for (i in range(1, 5)) { continue }", "", "i", "for(i in range(1, 5)) continue;"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf(incorrectStmtsLen, 1, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement, got=%T", program.Statements[0])
+		}
+		if tt.expectedKey == "" {
+			if stmt.Key != nil {
+				t.Errorf("stmt.Key was not nil, got=%+v", stmt.Key)
+			}
+		} else {
+			testIdentifier(t, stmt.Key, tt.expectedKey)
+		}
+		testIdentifier(t, stmt.Value, tt.expectedValue)
+		if stmt.String() != tt.expectedString {
+			t.Errorf("Wrong string, expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
 // func TestOperatorPrecedence(t *testing.T) {
 // 	tests := []struct {
 // 		input string
@@ -341,6 +494,42 @@ func TestIfExpression(t *testing.T) {
 // 	}
 // }
 
+func TestOperatorPrecedenceString(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	} {
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a == b && c < d", "((a == b) && (c < d))"},
+		{"!a || b", "((!a) || b)"},
+		{"a && b && c", "((a && b) && c)"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"2 ** -1", "(2 ** (-1))"},
+		{"x = 5", "(x = 5)"},
+		{"a = b = 1", "(a = (b = 1))"},
+		{"x += y * 2", "(x += (y * 2))"},
+		{"x = y == 1 || z", "(x = ((y == 1) || z))"},
+		{"x %= f(a = 1)", "(x %= f((a = 1)))"},
+		{"x **= y ** 2", "(x **= (y ** 2))"},
+		{"a[0] **= b = 2", "((a[0]) **= (b = 2))"},
+		{"a[i + 1] = b[0] *= 2", "((a[(i + 1)]) = ((b[0]) *= 2))"},
+		{"d[\"k\"][0] -= 1", "(((d[k])[0]) -= 1)"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("Wrong precedence, expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingInfixExpressions(t *testing.T) {
 	infixTests := []struct {
 		input string
@@ -431,6 +620,34 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		expected float64
+	} {
+		{"3.14;", 3.14},
+		{".5", 0.5},
+		{"1e-9", 1e-9},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf(notExprStmt, program.Statements[0])
+		}
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral, got=%T", stmt.Expression)
+		}
+		if literal.Val != tt.expected {
+			t.Errorf("literal.Val not %g, got=%g", tt.expected, literal.Val)
+		}
+	}
+}
+
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
@@ -550,6 +767,29 @@ func TestLetStatments(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []string{
+		"const x = 5;",
+		"const x = 5; fn() { let x = 1; x = 2 }",
+		"const x = 5; fn(x) { x += 1 }",
+		"let x = 5; const x = 6;",
+		"let x = 5; fn() { const x = 1; x }; x = 6",
+	}
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("Statement is not *ast.LetStatement, got=%T", program.Statements[0])
+		}
+		if stmt.Name.Val != "x" || stmt.IsConst() != (stmt.TokenLiteral() == "const") {
+			t.Errorf("Wrong statement for %q, got=%s", input, stmt)
+		}
+	}
+}
+
 func testLetStatements(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. Got=%q", s.TokenLiteral())
@@ -575,6 +815,182 @@ func testLetStatements(t *testing.T, s ast.Statement, name string) bool {
 	return true
 }
 
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input string
+		expectedError string
+	} {
+		{"let = 5;", "1:5: Expected next token to be ID, got = instead"},
+		{"let x = 1;\nadd(x, 2", "2:9: Expected next token to be ), got EOF instead"},
+		{"let x = 1;\n  * 2", "2:3: no prefix parse function * found"},
+		{"let s = \"abc;\nlet t = 1;", "1:9: Unterminated string literal"},
+		{"let s = 1 @ 2;", "1:11: Unexpected character '@'"},
+		{"let x = 1;\nbreak;", "2:1: break outside of a loop"},
+		{"while (true) { fn() { continue; } }", "1:23: continue outside of a loop"},
+		{"while (true) { let x = if (true) { break; }; }", "1:36: break cannot be used inside an expression"},
+		{"for (x in xs) { 1 + if (true) { continue; } }", "1:33: continue cannot be used inside an expression"},
+		{"while (true) { if (true) { break; } + 1 }", "1:28: break cannot be used inside an expression"},
+		{"while (true) { if (a) { 1 } else if (b) { continue; } * 2 }", "1:43: continue cannot be used inside an expression"},
+		{"while (true) { f(if (true) { break; }) }", "1:30: break cannot be used inside an expression"},
+		{"while (true { break; }", "1:13: Expected next token to be ), got { instead"},
+		{"for (x of xs) { x }", "1:8: Expected next token to be IN, got ID instead"},
+		{"for (1 in xs) { x }", "1:6: Expected next token to be ID, got INT instead"},
+		{"let a = [1];\na[0] + 1 = 2", "2:1: Invalid assignment target for ="},
+		{"f() -= 1", "1:1: Invalid assignment target for -="},
+		{"const x = 1;\nx = 2", "2:1: Cannot assign to const x declared at 1:1"},
+		{"const x = 1; x += 1", "1:14: Cannot assign to const x declared at 1:1"},
+		{"const n = 1; fn() { n = 2 }", "1:21: Cannot assign to const n declared at 1:1"},
+		{"const x = 1; let x = 2;", "1:18: Cannot redeclare const x declared at 1:1"},
+		{"const x = 1; const x = 2;", "1:20: Cannot redeclare const x declared at 1:1"},
+		{"const x = 1; for (x in [1]) { x }", "1:19: Cannot redeclare const x declared at 1:1"},
+		{"if (a) { 1 } else if { 2 }", "1:22: Expected next token to be (, got { instead"},
+		{"match (x) { + => 1 }", "1:13: Unexpected + in pattern"},
+		{"match (x) { [1, -a] => 1 }", "1:18: Unexpected ID in pattern"},
+		{"match (x) { {k: 1} => 1 }", "1:14: Unexpected ID in pattern"},
+		{"match (x) { 1 => 2 3 => 4 }", "1:20: Expected next token to be ,, got INT instead"},
+		{"match (x) { 1 if y 2 }", "1:20: Expected next token to be =>, got INT instead"},
+		{"const a = 1;\nmatch (x) { [b] => a = b }", "2:20: Cannot assign to const a declared at 1:1"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("Expected errors for %q, got none", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("Wrong error, expected=%q, got=%q", tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input string
+		expectedErrors []string
+		expectedStatements int
+	} {
+		{
+			"let = 5; let x = 1; let y 2; x",
+			[]string{
+				"1:5: Expected next token to be ID, got = instead",
+				"1:27: Expected next token to be =, got INT instead",
+			},
+			2,
+		},
+		{
+			"let x = (1 + ; let y = 2;",
+			[]string{"1:14: no prefix parse function ; found"},
+			1,
+		},
+		{
+			"let f = fn(a) { let = a; a };\nreturn f(1",
+			[]string{
+				"1:21: Expected next token to be ID, got = instead",
+				"2:11: Expected next token to be ), got EOF instead",
+			},
+			1,
+		},
+		{
+			"if (x) { x\nlet y = 1;",
+			[]string{"2:11: Expected next token to be }, got EOF instead"},
+			0,
+		},
+		{
+			"let x = 99999999999999999999; } x;",
+			[]string{
+				"1:9: Could not parse \"99999999999999999999\" as integer.",
+				"1:31: no prefix parse function } found",
+			},
+			0,
+		},
+		{
+			`{ "a": 1, "b" }`,
+			[]string{"1:15: Expected next token to be :, got } instead"},
+			0,
+		},
+		{
+			"let f = fn(x) { x + }; let g = 2",
+			[]string{"1:21: no prefix parse function } found"},
+			2,
+		},
+		{
+			"if (x { 1 } let y = 2",
+			[]string{"1:7: Expected next token to be ), got { instead"},
+			1,
+		},
+		{
+			"fn() { if (x { 1 } let y = 2; y }; 3",
+			[]string{"1:14: Expected next token to be ), got { instead"},
+			2,
+		},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("Wrong number of errors for %q, expected=%d, got=%d (%v)", tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+		for i, err := range errors {
+			if err.Error() != tt.expectedErrors[i] {
+				t.Errorf("Wrong error, expected=%q, got=%q", tt.expectedErrors[i], err)
+			}
+		}
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("Wrong number of statements for %q, expected=%d, got=%d", tt.input, tt.expectedStatements, len(program.Statements))
+		}
+	}
+}
+
+func TestParseErrorFields(t *testing.T) {
+	p := New(lexer.New("let x 5;"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("Expected 1 error, got=%d", len(errors))
+	}
+	err := errors[0]
+	if err.Kind != UnexpectedToken || err.Expected != token.ASSIGN || err.Got.Type != token.INT || err.Pos.String() != "1:7" {
+		t.Errorf("Wrong error fields, got=%+v", err)
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let x = [1, 2];\nadd(x[0], 3) * 2;"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	array := let.Val.(*ast.ArrayLiteral)
+	infix := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	call := infix.Left.(*ast.CallExpression)
+	index := call.Args[0].(*ast.IndexExpression)
+	tests := []struct {
+		node ast.Node
+		expectedPos string
+		expectedEnd string
+	} {
+		{program, "1:1", "2:17"},
+		{let, "1:1", "1:15"},
+		{array, "1:9", "1:15"},
+		{infix, "2:1", "2:17"},
+		{call, "2:1", "2:13"},
+		{index, "2:5", "2:9"},
+	}
+	for _, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedPos || tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("Wrong span for %q, expected=%s-%s, got=%s-%s", tt.node.String(), tt.expectedPos, tt.expectedEnd, tt.node.Pos(), tt.node.End())
+		}
+	}
+	if index.Pos().Offset != 20 {
+		t.Errorf("Wrong offset for %q, expected=20, got=%d", index.String(), index.Pos().Offset)
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -582,8 +998,8 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 
 	t.Errorf("Parser has %d errors.", len(errors))
-	for _, msg := range errors {
-		t.Errorf("Parser error: %q.", msg)
+	for _, err := range errors {
+		t.Errorf("Parser error: %q.", err)
 	}
 	t.FailNow()
 }
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"banana/ast"
+	"banana/token"
+)
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expr := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expr.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expr.Arms = append(expr.Arms, arm)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	expr.EndPos = p.curToken.End
+	return expr
+}
+
+// parseMatchArm parses an arm in a scope of its own, the names its pattern
+// binds are only visible in the guard and the body.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	p.enterScope(nil)
+	defer p.leaveScope()
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	return arm
+}
+
+// parsePattern parses the pattern starting at curToken and declares the
+// names it binds in the scope of the arm.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.ID:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		name := &ast.Identifier{Token: p.curToken, Val: p.curToken.Literal}
+		p.declare(name, false, name.Pos())
+		return &ast.BindingPattern{Name: name}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseDictPattern()
+	default:
+		literal := p.parseLiteralPattern()
+		if literal == nil {
+			return nil
+		}
+		return literal
+	}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		el := p.parsePattern()
+		if el == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, el)
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	pattern.EndPos = p.curToken.End
+	return pattern
+}
+
+func (p *Parser) parseDictPattern() ast.Pattern {
+	pattern := &ast.DictPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseLiteralPattern()
+		if key == nil {
+			return nil
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		val := p.parsePattern()
+		if val == nil {
+			return nil
+		}
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Vals = append(pattern.Vals, val)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	pattern.EndPos = p.curToken.End
+	return pattern
+}
+
+// parseLiteralPattern accepts integer, float, string and boolean literals,
+// numbers may be negated.
+func (p *Parser) parseLiteralPattern() *ast.LiteralPattern {
+	var val ast.Expression
+	switch p.curToken.Type {
+	case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE:
+		val = p.prefixParseFns[p.curToken.Type]()
+	case token.MINUS:
+		if !p.peekTokenIs(token.INT) && !p.peekTokenIs(token.FLOAT) {
+			p.addError(&ParseError{Kind: InvalidPattern, Got: p.peekToken, Pos: p.peekToken.Pos})
+			return nil
+		}
+		prefix := &ast.PrefixExpression{Token: p.curToken, Op: "-"}
+		p.nextToken()
+		prefix.Right = p.prefixParseFns[p.curToken.Type]()
+		if prefix.Right == nil {
+			return nil
+		}
+		val = prefix
+	default:
+		p.addError(&ParseError{Kind: InvalidPattern, Got: p.curToken, Pos: p.curToken.Pos})
+		return nil
+	}
+	if val == nil {
+		return nil
+	}
+	return &ast.LiteralPattern{Val: val}
+}
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"banana/ast"
+	"banana/token"
+)
+
+// declaration records where a name was bound so assignments to a const can
+// be rejected before anything runs. Blocks don't open a scope, only function
+// and macro bodies and match arms do. Names bound by earlier REPL lines or
+// by macros are unknown here, the evaluator and the compiler check those.
+type declaration struct {
+	isConst bool
+	pos token.Position
+}
+
+func (p *Parser) enterScope(params []*ast.Identifier) {
+	scope := map[string]declaration{}
+	for _, param := range params {
+		scope[param.Val] = declaration{pos: param.Pos()}
+	}
+	p.scopes = append(p.scopes, scope)
+}
+
+func (p *Parser) leaveScope() {
+	p.scopes = p.scopes[:len(p.scopes) - 1]
+}
+
+// declare binds name in the innermost scope, a const there can't be bound
+// again.
+func (p *Parser) declare(name *ast.Identifier, isConst bool, pos token.Position) {
+	scope := p.scopes[len(p.scopes) - 1]
+	if decl, ok := scope[name.Val]; ok && decl.isConst {
+		p.addError(&ParseError{Kind: ConstRedeclaration, Got: name.Token, Pos: name.Pos(), Declared: decl.pos})
+		return
+	}
+	scope[name.Val] = declaration{isConst: isConst, pos: pos}
+}
+
+// checkAssignment reports an assignment to target if the innermost binding
+// of the name is a const.
+func (p *Parser) checkAssignment(target *ast.Identifier) {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		decl, ok := p.scopes[i][target.Val]
+		if !ok {
+			continue
+		}
+		if decl.isConst {
+			p.addError(&ParseError{Kind: ConstAssignment, Got: target.Token, Pos: target.Pos(), Declared: decl.pos})
+		}
+		return
+	}
+}
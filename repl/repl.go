@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"banana/compiler"
 	"banana/evaluator"
 	"banana/lexer"
 	"banana/object"
 	"banana/parser"
+	"banana/vm"
 )
 
 const PROMPT = ">> "
@@ -43,8 +45,64 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// StartVM runs the REPL on the bytecode compiler and VM. The symbol table,
+// constants and globals are kept between lines so bindings persist. A line
+// that fails leaves the symbol table as it was, its globals may be unset.
+func StartVM(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	macroEnv := object.NewEnvironment()
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range evaluator.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	for {
+		fmt.Fprintf(out, PROMPT)
+		scanned := scanner.Scan()
+		if !scanned {
+			return
+		}
+
+		line := scanner.Text()
+		l := lexer.New(line)
+		p := parser.New(l)
+
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParserErrors(out, p.Errors())
+			return
+		}
+		evaluator.DefineMacros(program, macroEnv)
+		expanded := evaluator.ExpandMacros(program, macroEnv)
+		saved := symbolTable.Clone()
+		comp := compiler.NewWithState(symbolTable, constants)
+		err := comp.Compile(expanded)
+		if err != nil {
+			symbolTable = saved
+			fmt.Fprintf(out, "Compilation failed:\n\t%s\n", err)
+			continue
+		}
+		byteCode := comp.ByteCode()
+		constants = byteCode.Constants
+		machine := vm.NewWithGlobalsStore(byteCode, globals)
+		err = machine.Run()
+		if err != nil {
+			symbolTable = saved
+			fmt.Fprintf(out, "Executing bytecode failed:\n\t%s\n", err)
+			continue
+		}
+		lastPopped := machine.LastPoppedStackElem()
+		if lastPopped != nil {
+			io.WriteString(out, lastPopped.Inspect())
+			io.WriteString(out, "\n")
+		}
+	}
+}
+
+func printParserErrors(out io.Writer, errors []*parser.ParseError) {
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
 	}
 }
\ No newline at end of file
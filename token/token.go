@@ -1,19 +1,44 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type TokenType
 	Literal string
+	Pos Position // Where the token starts
+	End Position // Just past the last character of the token
+}
+
+// Position is a location in the source. Line and Column start at 1, Offset
+// is the byte offset from the start of the input.
+type Position struct {
+	Offset int
+	Line int
+	Column int
+}
+
+// IsValid reports whether the position was set by the lexer, nodes built by
+// hand or by macros have no position.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
 	ILLEGAL 	= "ILLEGAL"
 	EOF 		= "EOF"
+	COMMENT		= "COMMENT"
 
 	// Identifiers & literals
 	ID 			= "ID"
 	INT 		= "INT"
+	FLOAT		= "FLOAT"
 	STRING		= "STRING"
 	
 	// Operators
@@ -22,11 +47,24 @@ const (
 	MINUS 		= "-"
 	MULT	 	= "*"
 	DIV 		= "/"
+	MOD			= "%"
+	POW			= "**"
 	BANG 		= "!"
 	LT 			= "<"
 	GT 			= ">"
+	LT_EQ		= "<="
+	GT_EQ		= ">="
 	EQ 			= "=="
 	NOT_EQ 		= "!="
+	AND			= "&&"
+	OR			= "||"
+	PLUS_ASSIGN	= "+="
+	MINUS_ASSIGN	= "-="
+	MULT_ASSIGN	= "*="
+	DIV_ASSIGN	= "/="
+	MOD_ASSIGN	= "%="
+	POW_ASSIGN	= "**="
+	ARROW		= "=>"
 
 	// Delimiters
 	COMMA 		= ","
@@ -49,6 +87,13 @@ const (
 	ELSE 		= "ELSE"
 	RETURN 		= "RETURN"
 	MACRO		= "MACRO"
+	WHILE		= "WHILE"
+	BREAK		= "BREAK"
+	CONTINUE	= "CONTINUE"
+	FOR		= "FOR"
+	IN		= "IN"
+	CONST		= "CONST"
+	MATCH		= "MATCH"
 )
 
 var keywords = map[string]TokenType {
@@ -60,6 +105,13 @@ var keywords = map[string]TokenType {
 	"else": 	ELSE,
 	"return": 	RETURN,
 	"macro":	MACRO,
+	"while":	WHILE,
+	"break":	BREAK,
+	"continue":	CONTINUE,
+	"for":		FOR,
+	"in":		IN,
+	"const":	CONST,
+	"match":	MATCH,
 }
 
 func LookUpId(id string) TokenType {
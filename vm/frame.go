@@ -0,0 +1,20 @@
+package vm
+
+import (
+	"banana/code"
+	"banana/object"
+)
+
+type Frame struct {
+	cl *object.Closure
+	ip int
+	basePointer int // Stack pointer before the call, locals are stored from here
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
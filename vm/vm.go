@@ -0,0 +1,855 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"banana/code"
+	"banana/compiler"
+	"banana/evaluator"
+	"banana/object"
+	"banana/token"
+)
+
+const StackSize = 2048
+const GlobalsSize = 65536
+const MaxFrames = 1024
+
+const wrongNumErr = "Wrong number of args, got=%d, expected=%d"
+const divisionByZero = "Division by zero: %s %s %s"
+
+var (
+	NULL = evaluator.NULL
+	TRUE = evaluator.TRUE
+	FALSE = evaluator.FALSE
+)
+
+var infixOps = map[code.OpCode]string{
+	code.OpAdd: "+",
+	code.OpSub: "-",
+	code.OpMul: "*",
+	code.OpDiv: "/",
+	code.OpMod: "%",
+	code.OpPow: "**",
+	code.OpEqual: "==",
+	code.OpNotEqual: "!=",
+	code.OpGreaterThan: ">",
+	code.OpGreaterEqual: ">=",
+	code.OpLessThan: "<",
+	code.OpLessEqual: "<=",
+}
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp int // Always points to the next free slot, the top of the stack is stack[sp-1]
+
+	globals []object.Object
+
+	frames []*Frame
+	framesIndex int
+
+	checked bool // Report integer overflow instead of wrapping around
+}
+
+func New(byteCode *compiler.ByteCode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: byteCode.Instructions,
+		Positions: byteCode.Positions,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+	return &VM{
+		constants: byteCode.Constants,
+		stack: make([]object.Object, StackSize),
+		sp: 0,
+		globals: make([]object.Object, GlobalsSize),
+		frames: frames,
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsStore creates a VM that reads and writes globals in s, so
+// state can be carried from one run to the next.
+func NewWithGlobalsStore(byteCode *compiler.ByteCode, s []object.Object) *VM {
+	vm := New(byteCode)
+	vm.globals = s
+	return vm
+}
+
+// EnableCheckedArithmetic makes integer + - * / and ** report overflow as
+// an error instead of wrapping around.
+func (vm *VM) EnableCheckedArithmetic() {
+	vm.checked = true
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex - 1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("Stack overflow")
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) StackTop() object.Object {
+	if vm.sp == 0 {
+		return nil
+	}
+	return vm.stack[vm.sp - 1]
+}
+
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// RuntimeError is an error raised while running the bytecode, Pos is where
+// in the source the failing instruction was compiled from, if known.
+type RuntimeError struct {
+	Msg string
+	Pos token.Position
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	return e.Msg
+}
+
+func (vm *VM) Run() error {
+	err := vm.run()
+	if err == nil {
+		return nil
+	}
+	// A call that fails before its first instruction, e.g. on a stack
+	// overflow, is reported at the call.
+	frame := vm.currentFrame()
+	if frame.ip < 0 && vm.framesIndex > 1 {
+		frame = vm.frames[vm.framesIndex - 2]
+	}
+	return &RuntimeError{Msg: err.Error(), Pos: frame.cl.Fn.Positions.Lookup(frame.ip)}
+}
+
+func (vm *VM) run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.OpCode
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions()) - 1 {
+		vm.currentFrame().ip++
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.OpCode(ins[ip])
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip + 1:])
+			vm.currentFrame().ip += 2
+			err := vm.push(vm.constants[constIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow:
+			err := vm.executeBinaryOperation(op)
+			if err != nil {
+				return err
+			}
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual, code.OpLessThan, code.OpLessEqual:
+			err := vm.executeComparison(op)
+			if err != nil {
+				return err
+			}
+		case code.OpTrue:
+			err := vm.push(TRUE)
+			if err != nil {
+				return err
+			}
+		case code.OpFalse:
+			err := vm.push(FALSE)
+			if err != nil {
+				return err
+			}
+		case code.OpBang:
+			err := vm.executeBangOperator()
+			if err != nil {
+				return err
+			}
+		case code.OpMinus:
+			err := vm.executeMinusOperator()
+			if err != nil {
+				return err
+			}
+		case code.OpPop:
+			vm.pop()
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip + 1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip + 1:]))
+			vm.currentFrame().ip += 2
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpNull:
+			err := vm.push(NULL)
+			if err != nil {
+				return err
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip + 1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip + 1:])
+			vm.currentFrame().ip += 2
+			if vm.globals[globalIndex] == nil {
+				return fmt.Errorf("Global %d used before it was set", globalIndex)
+			}
+			err := vm.push(vm.globals[globalIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			slot := frame.basePointer + int(localIndex)
+			if cell, ok := vm.stack[slot].(*object.Cell); ok {
+				cell.Val = vm.pop()
+			} else {
+				vm.stack[slot] = vm.pop()
+			}
+		case code.OpBindLocal:
+			localIndex := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer + int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			val := vm.stack[frame.basePointer + int(localIndex)]
+			if cell, ok := val.(*object.Cell); ok {
+				val = cell.Val
+			}
+			err := vm.push(val)
+			if err != nil {
+				return err
+			}
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			slot := frame.basePointer + int(localIndex)
+			cell, ok := vm.stack[slot].(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Val: vm.stack[slot]}
+				vm.stack[slot] = cell
+			}
+			err := vm.push(cell)
+			if err != nil {
+				return err
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			def := evaluator.Builtins[builtinIndex]
+			err := vm.push(def.Builtin)
+			if err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex].(*object.Cell).Val)
+			if err != nil {
+				return err
+			}
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			currentClosure := vm.currentFrame().cl
+			currentClosure.Free[freeIndex].(*object.Cell).Val = vm.pop()
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure)
+			if err != nil {
+				return err
+			}
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip + 1:]))
+			vm.currentFrame().ip += 2
+			array := vm.buildArray(vm.sp - numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			err := vm.push(array)
+			if err != nil {
+				return err
+			}
+		case code.OpDict:
+			numElements := int(code.ReadUint16(ins[ip + 1:]))
+			vm.currentFrame().ip += 2
+			dict, err := vm.buildDict(vm.sp - numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+			err = vm.push(dict)
+			if err != nil {
+				return err
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err := vm.executeIndexExpression(left, index)
+			if err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			res := evaluator.SetIndex(left, index, val)
+			if errObj, ok := res.(*object.Error); ok {
+				return errors.New(errObj.Msg)
+			}
+			err := vm.push(res)
+			if err != nil {
+				return err
+			}
+		case code.OpDup2:
+			err := vm.push(vm.stack[vm.sp - 2])
+			if err != nil {
+				return err
+			}
+			err = vm.push(vm.stack[vm.sp - 2])
+			if err != nil {
+				return err
+			}
+		case code.OpDup:
+			err := vm.push(vm.stack[vm.sp - 1])
+			if err != nil {
+				return err
+			}
+		case code.OpMatchArray:
+			length := int(code.ReadUint16(ins[ip + 1:]))
+			vm.currentFrame().ip += 2
+			array, ok := vm.pop().(*object.Array)
+			err := vm.push(nativeBoolToBooleanObject(ok && len(array.Elements) == length))
+			if err != nil {
+				return err
+			}
+		case code.OpMatchDict:
+			_, ok := vm.pop().(*object.Dict)
+			err := vm.push(nativeBoolToBooleanObject(ok))
+			if err != nil {
+				return err
+			}
+		case code.OpMatchKey:
+			key := vm.pop()
+			dict := vm.pop()
+			_, ok := evaluator.DictPair(dict, key)
+			err := vm.push(nativeBoolToBooleanObject(ok))
+			if err != nil {
+				return err
+			}
+		case code.OpIter:
+			iterable := vm.pop()
+			it, ok := object.Iterate(iterable)
+			if !ok {
+				return fmt.Errorf("Not iterable: %s", iterable.Type())
+			}
+			err := vm.push(it)
+			if err != nil {
+				return err
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip + 1:]))
+			numVars := code.ReadUint8(ins[ip + 3:])
+			vm.currentFrame().ip += 3
+			err := vm.executeIterNext(pos, int(numVars))
+			if err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip + 1:])
+			numFree := code.ReadUint8(ins[ip + 3:])
+			vm.currentFrame().ip += 3
+			err := vm.pushClosure(int(constIndex), int(numFree))
+			if err != nil {
+				return err
+			}
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip + 1:])
+			vm.currentFrame().ip += 1
+			err := vm.executeCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			returnVal := vm.pop()
+			if vm.framesIndex == 1 {
+				// A return at the top level ends the program, the value
+				// stays readable through LastPoppedStackElem.
+				return nil
+			}
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err := vm.push(returnVal)
+			if err != nil {
+				return err
+			}
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err := vm.push(NULL)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unknown opcode %d", op)
+		}
+	}
+	return nil
+}
+
+// executeIterNext pushes the next key and value, or just the element for a
+// single loop variable, of the iterator on top of the stack. It leaves the
+// iterator in place and jumps to pos once the iterator is exhausted.
+func (vm *VM) executeIterNext(pos, numVars int) error {
+	// Decoding can't tell what a file leaves on the stack, only how much.
+	it, ok := vm.stack[vm.sp - 1].(*object.Iterator)
+	if !ok {
+		return fmt.Errorf("Not an iterator: %s", vm.stack[vm.sp - 1].Type())
+	}
+	if numVars == 1 {
+		val, ok := it.NextElement()
+		if !ok {
+			vm.currentFrame().ip = pos - 1
+			return nil
+		}
+		return vm.push(val)
+	}
+	key, val, ok := it.Next()
+	if !ok {
+		vm.currentFrame().ip = pos - 1
+		return nil
+	}
+	err := vm.push(key)
+	if err != nil {
+		return err
+	}
+	return vm.push(val)
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp - 1 - numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("Not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf(wrongNumErr, numArgs, cl.Fn.NumParameters)
+	}
+	frame := NewFrame(cl, vm.sp - numArgs)
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+	// The arguments are already on the stack and become the first locals,
+	// reserve the space for the remaining ones.
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	if vm.sp >= StackSize {
+		return fmt.Errorf("Stack overflow")
+	}
+	// Clear what an earlier call left in the slots, a cell there would
+	// make the first assignment write into a stale closure.
+	for i := frame.basePointer + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp - numArgs: vm.sp]
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+	if errObj, ok := result.(*object.Error); ok {
+		return errors.New(errObj.Msg)
+	}
+	if result == nil {
+		result = NULL
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("Not a function: %+v", constant)
+	}
+	// Free variables are always kept in cells, the compiler pushes the cell
+	// of a captured variable but a function capturing itself is a plain
+	// closure.
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		val := vm.stack[vm.sp - numFree + i]
+		if _, ok := val.(*object.Cell); !ok {
+			val = &object.Cell{Val: val}
+		}
+		free[i] = val
+	}
+	vm.sp = vm.sp - numFree
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+func (vm *VM) push(obj object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("Stack overflow")
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[vm.sp - 1]
+	vm.sp--
+	return obj
+}
+
+func (vm *VM) executeBinaryOperation(op code.OpCode) error {
+	right := vm.pop()
+	left := vm.pop()
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeBinaryStringOperation(op, left, right)
+	case op == code.OpMul && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return vm.executeStringRepetition(left, right)
+	case op == code.OpMul && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeStringRepetition(right, left)
+	default:
+		return vm.infixTypeError(op, left, right)
+	}
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.OpCode, left, right object.Object) error {
+	if op != code.OpAdd {
+		return vm.infixTypeError(op, left, right)
+	}
+	leftVal := left.(*object.String).Val
+	rightVal := right.(*object.String).Val
+	return vm.push(&object.String{Val: leftVal + rightVal})
+}
+
+func (vm *VM) executeStringRepetition(str, count object.Object) error {
+	val := str.(*object.String).Val
+	n := count.(*object.Integer).Val
+	if n < 0 {
+		return fmt.Errorf("Negative repeat count: %d", n)
+	}
+	if len(val) > 0 && n > math.MaxInt32 / int64(len(val)) {
+		return fmt.Errorf("String repetition too large")
+	}
+	return vm.push(&object.String{Val: strings.Repeat(val, int(n))})
+}
+
+func (vm *VM) executeBinaryIntegerOperation(op code.OpCode, left, right object.Object) error {
+	leftVal := left.(*object.Integer).Val
+	rightVal := right.(*object.Integer).Val
+	if (op == code.OpDiv || op == code.OpMod) && rightVal == 0 {
+		return fmt.Errorf(divisionByZero, left.Inspect(), infixOps[op], right.Inspect())
+	}
+	if op == code.OpPow && rightVal < 0 {
+		return vm.push(&object.Float{Val: math.Pow(float64(leftVal), float64(rightVal))})
+	}
+	res, overflow := evaluator.IntegerArithmetic(infixOps[op], leftVal, rightVal)
+	if overflow && vm.checked {
+		return fmt.Errorf("Integer overflow: %s %s %s", left.Inspect(), infixOps[op], right.Inspect())
+	}
+	return vm.push(&object.Integer{Val: res})
+}
+
+// executeBinaryFloatOperation handles a float with a float or an integer,
+// the integer is widened to a float.
+func (vm *VM) executeBinaryFloatOperation(op code.OpCode, left, right object.Object) error {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	var res float64
+	switch op {
+	case code.OpAdd:
+		res = leftVal + rightVal
+	case code.OpSub:
+		res = leftVal - rightVal
+	case code.OpMul:
+		res = leftVal * rightVal
+	case code.OpDiv:
+		if rightVal == 0 {
+			return fmt.Errorf(divisionByZero, left.Inspect(), infixOps[op], right.Inspect())
+		}
+		res = leftVal / rightVal
+	case code.OpMod:
+		if rightVal == 0 {
+			return fmt.Errorf(divisionByZero, left.Inspect(), infixOps[op], right.Inspect())
+		}
+		res = math.Mod(leftVal, rightVal)
+	case code.OpPow:
+		res = math.Pow(leftVal, rightVal)
+	default:
+		return fmt.Errorf("Unknown float operator: %d", op)
+	}
+	return vm.push(&object.Float{Val: res})
+}
+
+func (vm *VM) executeComparison(op code.OpCode) error {
+	right := vm.pop()
+	left := vm.pop()
+	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+		return vm.executeIntegerComparison(op, left, right)
+	}
+	switch {
+	case isNumber(left) && isNumber(right):
+		return vm.executeFloatComparison(op, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeStringComparison(op, left, right)
+	case op == code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case op == code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	default:
+		return vm.infixTypeError(op, left, right)
+	}
+}
+
+func (vm *VM) executeIntegerComparison(op code.OpCode, left, right object.Object) error {
+	leftVal := left.(*object.Integer).Val
+	rightVal := right.(*object.Integer).Val
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal >= rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal <= rightVal))
+	default:
+		return fmt.Errorf("Unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeFloatComparison(op code.OpCode, left, right object.Object) error {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal >= rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal <= rightVal))
+	default:
+		return fmt.Errorf("Unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeStringComparison(op code.OpCode, left, right object.Object) error {
+	leftVal := left.(*object.String).Val
+	rightVal := right.(*object.String).Val
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal >= rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal <= rightVal))
+	default:
+		return fmt.Errorf("Unknown operator: %d", op)
+	}
+}
+
+// infixTypeError reports an unsupported infix operation.
+func (vm *VM) infixTypeError(op code.OpCode, left, right object.Object) error {
+	if left.Type() != right.Type() {
+		return fmt.Errorf("Type mismatch: %s %s %s", left.Type(), infixOps[op], right.Type())
+	}
+	return fmt.Errorf("Unknown operator: %s %s %s", left.Type(), infixOps[op], right.Type())
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex - startIndex)
+	for i := startIndex; i < endIndex; i++ {
+		elements[i - startIndex] = vm.stack[i]
+	}
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildDict(startIndex, endIndex int) (object.Object, error) {
+	pairs := make(map[object.DictKey]object.DictPair)
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		val := vm.stack[i + 1]
+		dictKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("Unusable as dict key: %s", key.Type())
+		}
+		pairs[dictKey.DictKey()] = object.DictPair{Key: key, Val: val}
+	}
+	return &object.Dict{Pairs: pairs}, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
+	case left.Type() == object.DICT_OBJ:
+		return vm.executeDictIndex(left, index)
+	default:
+		return fmt.Errorf("Index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Val
+	max := int64(len(arrayObject.Elements) - 1)
+	if idx < 0 || idx > max {
+		return vm.push(NULL)
+	}
+	return vm.push(arrayObject.Elements[idx])
+}
+
+// executeStringIndex indexes by code point, not by byte.
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	runes := []rune(str.(*object.String).Val)
+	idx := index.(*object.Integer).Val
+	if idx < 0 || idx >= int64(len(runes)) {
+		return vm.push(NULL)
+	}
+	return vm.push(&object.String{Val: string(runes[idx])})
+}
+
+func (vm *VM) executeDictIndex(dict, index object.Object) error {
+	dictObject := dict.(*object.Dict)
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("Unusable as dict key: %s", index.Type())
+	}
+	pair, ok := dictObject.Pairs[key.DictKey()]
+	if !ok {
+		return vm.push(NULL)
+	}
+	return vm.push(pair.Val)
+}
+
+func (vm *VM) executeBangOperator() error {
+	operand := vm.pop()
+	switch operand {
+	case TRUE:
+		return vm.push(FALSE)
+	case FALSE:
+		return vm.push(TRUE)
+	case NULL:
+		return vm.push(TRUE)
+	default:
+		return vm.push(FALSE)
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+	switch operand := operand.(type) {
+	case *object.Integer:
+		if vm.checked && operand.Val == math.MinInt64 {
+			return fmt.Errorf("Integer overflow: -(%s)", operand.Inspect())
+		}
+		return vm.push(&object.Integer{Val: -operand.Val})
+	case *object.Float:
+		return vm.push(&object.Float{Val: -operand.Val})
+	default:
+		return fmt.Errorf("Unknown operator: -%s", operand.Type())
+	}
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Val)
+	}
+	return obj.(*object.Float).Val
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
@@ -0,0 +1,821 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+	"banana/ast"
+	"banana/code"
+	"banana/compiler"
+	"banana/lexer"
+	"banana/object"
+	"banana/parser"
+)
+
+type vmTestCase struct {
+	input string
+	expected interface{}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		expectedMessage string
+	} {
+		{"5 + true;", "Type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5", "Type mismatch: INTEGER + BOOLEAN"},
+		{`1 < "a"`, "Type mismatch: INTEGER < STRING"},
+		{"true <= false", "Unknown operator: BOOLEAN <= BOOLEAN"},
+		{"-true;", "Unknown operator: -BOOLEAN"},
+		{"true + false;", "Unknown operator: BOOLEAN + BOOLEAN"},
+		{`"hello" - "world"`, "Unknown operator: STRING - STRING"},
+		{`"ab" * -1`, "Negative repeat count: -1"},
+		{"1[0]", "Index operator not supported: INTEGER"},
+		{"{[1]: 2}", "Unusable as dict key: ARRAY"},
+		{"fn() { 1; }(1);", "Wrong number of args, got=1, expected=0"},
+		{"fn(a, b) { a + b; }(1);", "Wrong number of args, got=1, expected=2"},
+		{"1(2)", "Not a function: INTEGER"},
+		{`len(1)`, "Arg to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "Wrong number of args, got=2, expected=1"},
+		{"let f = fn() { f() }; f()", "Stack overflow"},
+		{"5; true + false; 5", "Unknown operator: BOOLEAN + BOOLEAN"},
+		{"let x = 0; 1 / x", "Division by zero: 1 / 0"},
+		{"let x = 0; 7 % x", "Division by zero: 7 % 0"},
+		{"let x = 0.0; 1 / x", "Division by zero: 1 / 0.0"},
+		{"for (x in 5) { x }", "Not iterable: INTEGER"},
+		{"let x = 1; x += true", "Type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1, 2]; a[2] = 3", "Index out of bounds: 2, length 2"},
+		{`let a = [1, 2]; a["0"] = 3`, "Array index must be INTEGER, got STRING"},
+		{"let d = {}; d[[1]] = 3", "Unusable as dict key: ARRAY"},
+		{`let s = "abc"; s[0] = "x"`, "Index assignment not supported: STRING"},
+		{"let d = {}; d[1] += 1", "Type mismatch: NULL + INTEGER"},
+		{"insert([1], 2, 0)", "Index out of bounds: 2, length 1"},
+		{"range(0, 1, 0)", "Range step cannot be zero"},
+	}
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		vm := New(comp.ByteCode())
+		err = vm.Run()
+		if err == nil {
+			t.Errorf("Expected VM error but got none for %q", tt.input)
+			continue
+		}
+		if err.(*RuntimeError).Msg != tt.expectedMessage {
+			t.Errorf("Wrong error message, expected=%q, got=%q", tt.expectedMessage, err)
+		}
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input string
+		expectedMessage string
+	} {
+		{"let max = 9223372036854775807; max + 1", "Integer overflow: 9223372036854775807 + 1"},
+		{"let min = -9223372036854775807 - 1; min - 1", "Integer overflow: -9223372036854775808 - 1"},
+		{"let big = 4294967296; big * big", "Integer overflow: 4294967296 * 4294967296"},
+		{"let two = 2; two ** 63", "Integer overflow: 2 ** 63"},
+		{"let min = -9223372036854775807 - 1; min / -1", "Integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775807 - 1; -min", "Integer overflow: -(-9223372036854775808)"},
+		{"let min = -9223372036854775807 - 1; abs(min)", "Integer overflow: abs(-9223372036854775808)"},
+		{"let min = -9223372036854775807; -min", ""},
+		{"let max = 9223372036854775807; max - 1", ""},
+		{"let two = 2; two ** 62", ""},
+	}
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		vm := New(comp.ByteCode())
+		vm.EnableCheckedArithmetic()
+		err = vm.Run()
+		if tt.expectedMessage == "" {
+			if err != nil {
+				t.Errorf("Unexpected VM error for %q: %s", tt.input, err)
+			}
+			continue
+		}
+		if err == nil || err.(*RuntimeError).Msg != tt.expectedMessage {
+			t.Errorf("Wrong error for %q, expected=%q, got=%v", tt.input, tt.expectedMessage, err)
+		}
+	}
+
+	// Without checking, arithmetic wraps around.
+	runVmTests(t, []vmTestCase{
+		{"let max = 9223372036854775807; max + 1", -9223372036854775808},
+		{"let min = -9223372036854775807 - 1; -min", -9223372036854775808},
+	})
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase {
+		{
+			`
+			let countDown = fn(x) {
+				if (x == 0) {
+					return 0;
+				} else {
+					countDown(x - 1);
+				}
+			};
+			countDown(1);
+			`,
+			0,
+		},
+		{
+			`
+			let wrapper = fn() {
+				let countDown = fn(x) {
+					if (x == 0) {
+						return 0;
+					} else {
+						countDown(x - 1);
+					}
+				};
+				countDown(1);
+			};
+			wrapper();
+			`,
+			0,
+		},
+		{
+			`
+			let fibonacci = fn(x) {
+				if (x == 0) {
+					return 0;
+				}
+				if (x == 1) {
+					return 1;
+				}
+				fibonacci(x - 1) + fibonacci(x - 2);
+			};
+			fibonacci(15);
+			`,
+			610,
+		},
+		// The function refers to itself through its binding, which may change.
+		{"let counter = fn() { counter = 5; 1 }; counter(); counter", 5},
+		{"let f = fn() { f }; let g = f; f = 3; g()", 3},
+		{"let h = fn() { let f = fn() { f }; let g = f; f = 3; g() }; h()", 3},
+		{"const fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)", 120},
+	}
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase {
+		{
+			`
+			let newClosure = fn(a) {
+				fn() { a; };
+			};
+			let closure = newClosure(99);
+			closure();
+			`,
+			99,
+		},
+		{
+			`
+			let newAdder = fn(a, b) {
+				let c = a + b;
+				fn(d) { c + d };
+			};
+			let adder = newAdder(1, 2);
+			adder(8);
+			`,
+			11,
+		},
+		{
+			`
+			let newAdderOuter = fn(a, b) {
+				let c = a + b;
+				fn(d) {
+					let e = d + c;
+					fn(f) { e + f; };
+				};
+			};
+			let newAdderInner = newAdderOuter(1, 2)
+			let adder = newAdderInner(3);
+			adder(8);
+			`,
+			14,
+		},
+	}
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase {
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`print("hello", "world!")`, NULL},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, NULL},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, NULL},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, NULL},
+		{`push([], 1)`, []int{1}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase {
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let one = fn() { 1; }; let two = fn() { 2; }; one() + two()", 3},
+		{"let a = fn() { 1 }; let b = fn() { a() + 1 }; let c = fn() { b() + 1 }; c();", 3},
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let noReturn = fn() { }; noReturn();", NULL},
+		{"let one = fn() { let one = 1; one }; one();", 1},
+		{"let oneAndTwo = fn() { let one = 1; let two = 2; one + two; }; oneAndTwo();", 3},
+		{"let globalSeed = 50; let minusOne = fn() { let num = 1; globalSeed - num; }; minusOne();", 49},
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2) + sum(3, 4);", 10},
+		{"let returnsOneReturner = fn() { fn() { 1; }; }; returnsOneReturner()();", 1},
+		{"fn(x) { x; }(5)", 5},
+	}
+	runVmTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase {
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][0 + 2]", 3},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[][0]", NULL},
+		{"[1, 2, 3][99]", NULL},
+		{"[1][-1]", NULL},
+		{"{1: 1, 2: 2}[1]", 1},
+		{"{1: 1, 2: 2}[2]", 2},
+		{"{1: 1}[0]", NULL},
+		{"{}[0]", NULL},
+		{`{"foo": 5}["foo"]`, 5},
+	}
+	runVmTests(t, tests)
+}
+
+func TestDictLiterals(t *testing.T) {
+	tests := []vmTestCase {
+		{"{}", map[object.DictKey]int64{}},
+		{
+			"{1: 2, 2: 3}",
+			map[object.DictKey]int64{
+				(&object.Integer{Val: 1}).DictKey(): 2,
+				(&object.Integer{Val: 2}).DictKey(): 3,
+			},
+		},
+		{
+			"{1 + 1: 2 * 2, 3 + 3: 4 * 4}",
+			map[object.DictKey]int64{
+				(&object.Integer{Val: 2}).DictKey(): 4,
+				(&object.Integer{Val: 6}).DictKey(): 16,
+			},
+		},
+	}
+	runVmTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase {
+		{"[]", []int{}},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []vmTestCase {
+		{`"banana"`, "banana"},
+		{`"ban" + "ana"`, "banana"},
+		{`"ban" + "ana" + "split"`, "bananasplit"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase {
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = 2; one + two", 3},
+		{"let one = 1; let two = one + one; one + two", 3},
+	}
+	runVmTests(t, tests)
+}
+
+func TestGlobalsStore(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+	inputs := []string{"let a = 2;", "let b = a * 3;", "a + b"}
+	var vm *VM
+	for _, input := range inputs {
+		comp := compiler.NewWithState(symbolTable, constants)
+		err := comp.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		byteCode := comp.ByteCode()
+		constants = byteCode.Constants
+		vm = NewWithGlobalsStore(byteCode, globals)
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("VM error: %s", err)
+		}
+	}
+	testExpectedObject(t, 8, vm.LastPoppedStackElem())
+}
+
+func TestRedeclarationKeepsBinding(t *testing.T) {
+	tests := []vmTestCase {
+		{"let x = 1; let f = fn() { x }; let x = 2; f()", 2},
+		{"let g = fn() { let x = 1; let f = fn() { x }; let x = 2; f() }; g()", 2},
+		{"let x = 1; let x = x + 1; x", 2},
+		{"let f = fn(a, a) { a }; f(1, 2)", 2},
+	}
+	runVmTests(t, tests)
+}
+
+func TestConstBindings(t *testing.T) {
+	tests := []vmTestCase {
+		{"const x = 2; x * 3", 6},
+		{"const x = 1; let f = fn() { let x = 2; x += 1; x }; f() + x", 4},
+		{"let x = 1; const x = 5; x", 5},
+	}
+	runVmTests(t, tests)
+}
+
+// A line that fails at runtime can leave a global it defines unset, reading
+// it later is an error rather than a nil on the stack.
+func TestUnsetGlobal(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	globals := make([]object.Object, GlobalsSize)
+	inputs := []string{"let a = 1 / 0;", "a + 1"}
+	expected := []string{"Division by zero: 1 / 0", "Global 0 used before it was set"}
+	for i, input := range inputs {
+		comp := compiler.NewWithState(symbolTable, []object.Object{})
+		err := comp.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		err = NewWithGlobalsStore(comp.ByteCode(), globals).Run()
+		if err == nil || err.(*RuntimeError).Msg != expected[i] {
+			t.Errorf("Wrong VM error for %q, expected=%q, got=%v", input, expected[i], err)
+		}
+	}
+}
+
+func TestUnknownOpcode(t *testing.T) {
+	ins := append(code.Make(code.OpTrue), 255)
+	err := New(&compiler.ByteCode{Instructions: ins}).Run()
+	if err == nil || err.Error() != "Unknown opcode 255" {
+		t.Errorf("Wrong VM error, expected=%q, got=%v", "Unknown opcode 255", err)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []vmTestCase {
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{`1 && "a"`, true},
+		{"if (1 < 2 && 2 < 3) { 10 } else { 20 }", 10},
+		{"let boom = fn() { [][0] + 1 }; false && boom()", false},
+		{"let boom = fn() { [][0] + 1 }; true || boom()", true},
+	}
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase {
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 } ", 20},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 }", NULL},
+		{"if (false) { 10 }", NULL},
+		{"if (true) { }", NULL},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", NULL},
+	}
+	runVmTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []vmTestCase {
+		{"match (1) { 1 => 10, _ => 20 }", 10},
+		{"match (2) { 1 => 10, _ => 20 }", 20},
+		{"match (3) { 1 => 10, 2 => 20 }", NULL},
+		{"match (-1) { 1 => 10, -1 => 20 }", 20},
+		{"match (2.0) { 2 => 10 }", 10},
+		{`match ("b") { "a" => 10, "b" => 20 }`, 20},
+		{`match ("1") { 1 => 10, true => 20, _ => 30 }`, 30},
+		{"match (1 < 2) { false => 10, true => 20 }", 20},
+		{"match (5) { n => n * 2 }", 10},
+		{"match ([1, 2]) { [a] => a, [a, b] => a + b, _ => 0 }", 3},
+		{"match ([1, [2, 3]]) { [1, [a, 4]] => a, [1, [a, b]] => a * b }", 6},
+		{"match ([]) { [] => 1 }", 1},
+		{"match ({}) { [] => 1, {} => 2 }", 2},
+		{`match ({"a": 1, "b": [2]}) { {"c": c} => c, {"b": [x], "a": y} => x + y }`, 3},
+		{`match ({"a": 1}) { {"a": 2} => 10, {"a": _} => 20 }`, 20},
+		{`match ({1: 5, true: 6}) { {1: a, true: b} => a * b }`, 30},
+		{"match ([1, 2]) { {} => 1, [a, b] if a > b => 2, [a, b] if a < b => 3 }", 3},
+		{"match (7) { n if n % 2 == 0 => 1, n => n }", 7},
+		{"let x = 1; match ([2]) { [x] => x }; x", 1},
+		{"let x = 1; match (5) { x if x > 10 => 0, _ => 0 }; x", 1},
+		{"let x = 1; match (5) { x => x = 7 }; x", 1},
+		{"const c = 1; match (2) { c => c += 1 }", 3},
+		{"let x = 1; match ([2, 3]) { [x, 4] => x }; x", 1},
+		{"let f = fn(v) { match (v) { [a, b] => a + b, _ => -1 } }; f([3, 4]) + f(1)", 6},
+		{"let f = fn(v) { let g = fn() { match (v) { [a] => a } }; g() }; f([9])", 9},
+		{"let r = []; for (v in [[1], 2, [3, 4]]) { r = push(r, match (v) { [a] => a, [a, b] => b, _ => 0 }) }; r", []int{1, 0, 4}},
+		// Each match binds new variables, closures made by earlier ones keep theirs.
+		{"let fs = []; for (v in [1, 2]) { fs = push(fs, match (v) { n => fn() { n } }) }; fs[0]()", 1},
+		{"let f = fn() { let fs = []; for (v in [1, 2]) { fs = push(fs, match (v) { n => fn() { n } }) }; fs[0]() }; f()", 1},
+		{"let f = fn() { match (1) { n => if (true) { let g = fn() { n += 1 }; g(); n } } }; f()", 2},
+	}
+	runVmTests(t, tests)
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []vmTestCase {
+		{"while (false) { 10 }", NULL},
+		{"while (true) { break; }", NULL},
+		{"while (true) { break; 10 }; 20", 20},
+		{"let f = fn() { while (true) { return 10; } }; f()", 10},
+		{"let f = fn() { while (true) { while (true) { break; } return 10; } }; f()", 10},
+		{"let f = fn(x) { while (x) { if (x > 5) { return x; } break; } }; f(10)", 10},
+		{"let f = fn(x) { while (x) { if (x > 5) { return x; } break; } }; f(1)", NULL},
+		{"let f = fn() { while (true) { break; } }; f()", NULL},
+	}
+	runVmTests(t, tests)
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []vmTestCase {
+		{"for (x in [1, 2, 3]) { x }", NULL},
+		{"for (x in []) { x }; 5", 5},
+		{"let f = fn(xs) { for (x in xs) { if (x > 1) { return x; } } }; f([1, 2, 3])", 2},
+		{"let f = fn(xs) { for (i, x in xs) { if (x > 1) { return i; } } }; f([1, 2, 3])", 1},
+		{`let f = fn(d) { for (k in d) { return k; } }; f({"b": 1, "a": 2})`, "a"},
+		{`let f = fn(d) { for (k, v in d) { return v; } }; f({3: 1, 1: 2, 2: 3})`, 2},
+		{`let f = fn(s) { for (c in s) { if (c != "a") { return c; } } }; f("aé")`, "é"},
+		{"let f = fn() { for (i in range(10, 0, -3)) { if (i < 5) { return i; } } }; f()", 4},
+		{"let f = fn() { for (i in range(3)) { if (i < 1) { continue; } return i; } }; f()", 1},
+		{"let f = fn() { for (i in range(5)) { for (j in range(i)) { break; } if (i > 2) { return i; } } }; f()", 3},
+		{"for (i in range(3)) { }; i", 2},
+		{"for (i in range(1000)) { for (j in range(2)) { break; } }; i", 999},
+	}
+	runVmTests(t, tests)
+}
+
+// Leaving a loop early must not leave values behind on the stack, a few
+// thousand iterations would overflow it otherwise.
+func TestLoopControlKeepsStack(t *testing.T) {
+	tests := []vmTestCase {
+		{"let s = 0; for (x in range(0, 5000, 1)) { s + 1; if (true) { continue; } }; s", 0},
+		{"let s = 0; for (x in range(0, 5000, 1)) { if (x % 2 == 0) { continue; } s += x; }; s", 6250000},
+		{"let s = 0; for (x in range(0, 5000, 1)) { while (true) { s += 1; if (s > 0) { break; } } }; s", 5000},
+		{"let i = 0; while (i < 5000) { i += 1; if (i > 2) { if (true) { continue; } } else { 1 } }; i", 5000},
+		{"let f = fn() { let n = 0; for (x in range(5000)) { n += 1; if (x > 1) { continue; } }; n }; f()", 5000},
+	}
+	runVmTests(t, tests)
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		vm := New(comp.ByteCode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("VM error: %s", err)
+		}
+		if vm.sp != 0 {
+			t.Errorf("Stack not empty after %q, sp=%d", tt.input, vm.sp)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase {
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 2", 3},
+		{"let a = 1; let b = 2; a = b = 3; a + b", 6},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4", 2},
+		{"let x = 5; x **= 2; x", 25},
+		{"let a = [3]; a[0] **= 3; a", []int{27}},
+		{`let s = "a"; s += "b"; s *= 2; s`, "abab"},
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", 5},
+		{"let x = 1; let f = fn() { let x = 2; x = 3 }; f(); x", 1},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n }; f()", 2},
+		{"let f = fn() { let n = 0; let g = fn() { fn() { n += 10 } }; g()(); n }; f()", 10},
+		{"let f = fn(a) { let g = fn() { a *= 2 }; g(); a }; f(4)", 8},
+		// Each call gets fresh locals, a cell left behind by an earlier call
+		// must not be written through.
+		{"let f = fn() { let n = 0; let g = fn() { n }; n = 1; g }; let ga = f(); let gb = f(); ga() + gb()", 2},
+		{"let mk = fn() { let v = 1; fn() { v += 1 } }; let a = mk(); a(); let b = mk(); b(); a()", 3},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; if (i == 3) { continue; } sum += i; }; sum", 12},
+		{"let f = fn() { let i = 0; let sum = 0; while (i < 5) { i += 1; if (i == 3) { continue; } sum += i; } sum }; f()", 12},
+		{"let n = 0; while (true) { n += 1; if (n >= 4) { break; } }; n", 4},
+		{"let sum = 0; for (k, v in {1: 10, 2: 20}) { sum += k * v }; sum", 50},
+	}
+	runVmTests(t, tests)
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []vmTestCase {
+		{"let a = [1, 2, 3]; a[1] = 5; a", []int{1, 5, 3}},
+		{"let a = [1, 2, 3]; a[0] += 10", 11},
+		{"let a = [[1], [2]]; a[1][0] *= 7; a[1]", []int{14}},
+		{"let a = [1]; let b = a; b[0] = 2; a", []int{2}},
+		{`let d = {"a": 1}; d["b"] = 2; d["a"] -= 1; d["a"] + d["b"]`, 2},
+		{"let i = 0; let a = [0, 0]; let next = fn() { i += 1; i - 1 }; a[next()] += 5; a[0] + i", 6},
+		{"let f = fn() { let a = []; for (i in range(4)) { append!(a, i * i) } a }; f()", []int{0, 1, 4, 9}},
+		{"let a = [1, 3]; insert(a, 1, 2); insert(a, 3, 4); insert(a, 0, 0)", []int{0, 1, 2, 3, 4}},
+		{"let a = [1, 2, 3]; delete(a, 1) + len(a)", 4},
+		{`let d = {"a": 1, "b": 2}; delete(d, "a")`, 1},
+		{`let d = {"a": 1}; delete(d, "x")`, NULL},
+	}
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase {
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
+	}
+	runVmTests(t, tests)
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase {
+		{"1", 1},
+		{"2", 2},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"1 * 2", 2},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"5 * (2 + 10)", 60},
+		{"-5", -5},
+		{"-10", -10},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+	runVmTests(t, tests)
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []vmTestCase {
+		{`"abc"[0]`, "a"},
+		{`"naïve"[2]`, "ï"},
+		{`"abc"[3]`, NULL},
+		{`len("バナナ")`, 3},
+		{`len(bytes("バナナ"))`, 9},
+	}
+	runVmTests(t, tests)
+}
+
+func TestComparisonAndArithmeticOperators(t *testing.T) {
+	tests := []vmTestCase {
+		{"7 % 3", 1},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"2 ** -1", 0.5},
+		{"7.5 % 2", 1.5},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"1.5 >= 1", true},
+		{`"apple" < "banana"`, true},
+		{`"z" >= "zz"`, false},
+		{`"a" == "a"`, true},
+		{`"ab" * 3`, "ababab"},
+		{`2 * "xy"`, "xyxy"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase {
+		{"3.5", 3.5},
+		{"-.5", -0.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5.0},
+		{"1 == 1.0", true},
+		{"1 < 1.5", true},
+		{"2.5 > 3", false},
+		{"sqrt(2.25)", 1.5},
+		{"let half = fn(x) { x / 2.0 }; half(5)", 2.5},
+	}
+	runVmTests(t, tests)
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		vm := New(comp.ByteCode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("VM error: %s", err)
+		}
+		stackElem := vm.LastPoppedStackElem()
+		testExpectedObject(t, tt.expected, stackElem)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func testExpectedObject(t *testing.T, expected interface{}, actual object.Object) {
+	t.Helper()
+	switch expected := expected.(type) {
+	case int:
+		err := testIntegerObject(int64(expected), actual)
+		if err != nil {
+			t.Errorf("testIntegerObject failed: %s", err)
+		}
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject failed: %s", err)
+		}
+	case bool:
+		err := testBooleanObject(expected, actual)
+		if err != nil {
+			t.Errorf("testBooleanObject failed: %s", err)
+		}
+	case string:
+		err := testStringObject(expected, actual)
+		if err != nil {
+			t.Errorf("testStringObject failed: %s", err)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("Object is not Array, got=%T (%+v)", actual, actual)
+			return
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("Wrong num of elements, expected=%d, got=%d", len(expected), len(array.Elements))
+			return
+		}
+		for i, expectedElem := range expected {
+			err := testIntegerObject(int64(expectedElem), array.Elements[i])
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
+	case map[object.DictKey]int64:
+		dict, ok := actual.(*object.Dict)
+		if !ok {
+			t.Errorf("Object is not Dict, got=%T (%+v)", actual, actual)
+			return
+		}
+		if len(dict.Pairs) != len(expected) {
+			t.Errorf("Dict has wrong num of pairs, expected=%d, got=%d", len(expected), len(dict.Pairs))
+			return
+		}
+		for expectedKey, expectedVal := range expected {
+			pair, ok := dict.Pairs[expectedKey]
+			if !ok {
+				t.Errorf("No pair for given key in pairs")
+				continue
+			}
+			err := testIntegerObject(expectedVal, pair.Val)
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
+	case *object.Null:
+		if actual != NULL {
+			t.Errorf("Object is not NULL, got=%T (%+v)", actual, actual)
+		}
+	}
+}
+
+func testIntegerObject(expected int64, actual object.Object) error {
+	result, ok := actual.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("Object is not Integer, got=%T (%+v)", actual, actual)
+	}
+	if result.Val != expected {
+		return fmt.Errorf("Object has wrong value, got=%d, expected=%d", result.Val, expected)
+	}
+	return nil
+}
+
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("Object is not Float, got=%T (%+v)", actual, actual)
+	}
+	if result.Val != expected {
+		return fmt.Errorf("Object has wrong value, got=%g, expected=%g", result.Val, expected)
+	}
+	return nil
+}
+
+func testBooleanObject(expected bool, actual object.Object) error {
+	result, ok := actual.(*object.Boolean)
+	if !ok {
+		return fmt.Errorf("Object is not Boolean, got=%T (%+v)", actual, actual)
+	}
+	if result.Val != expected {
+		return fmt.Errorf("Object has wrong value, got=%t, expected=%t", result.Val, expected)
+	}
+	return nil
+}
+
+func testStringObject(expected string, actual object.Object) error {
+	result, ok := actual.(*object.String)
+	if !ok {
+		return fmt.Errorf("Object is not String, got=%T (%+v)", actual, actual)
+	}
+	if result.Val != expected {
+		return fmt.Errorf("Object has wrong value, got=%q, expected=%q", result.Val, expected)
+	}
+	return nil
+}
+
+func TestRuntimeErrorPositions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	} {
+		{"1;\ntrue + 1", "2:1: Type mismatch: BOOLEAN + INTEGER"},
+		{"let f = fn(x) {\n  x / 0\n};\nf(1)", "2:3: Division by zero: 1 / 0"},
+		{"let f = fn(a) { a };\nf()", "2:1: Wrong number of args, got=0, expected=1"},
+		{"let f = fn() { f() };\nf()", "1:16: Stack overflow"},
+		{"1;\n  len(1)", "2:3: Arg to `len` not supported, got INTEGER"},
+		{"let a = [1];\na[0] + a[1]", "2:1: Type mismatch: INTEGER + NULL"},
+	}
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		err = New(comp.ByteCode()).Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Wrong VM error for %q, expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}